@@ -0,0 +1,66 @@
+package pkg
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"io"
+)
+
+// WriteZip streams the package into a zip in the pkg2zip layout, the same one Install writes to disk
+func WriteZip(w io.Writer, p *Pkg, opts InstallOptions) error {
+	files, err := p.installFiles(opts.License)
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+	for _, file := range files {
+		if file.IsDir() {
+			if _, err := zw.Create(file.name + "/"); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := writeZipFile(zw, file.name, file.newReader()); err != nil {
+			return &ItemError{Name: file.Name, Err: err}
+		}
+	}
+	return zw.Close()
+}
+
+// WriteTar is WriteZip for tar archives
+func WriteTar(w io.Writer, p *Pkg, opts InstallOptions) error {
+	files, err := p.installFiles(opts.License)
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+	for _, file := range files {
+		if file.IsDir() {
+			err := tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeDir,
+				Name:     file.name + "/",
+				Mode:     0755,
+			})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     file.name,
+			Mode:     0644,
+			Size:     int64(file.Size),
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, file.newReader()); err != nil {
+			return &ItemError{Name: file.Name, Err: err}
+		}
+	}
+	return tw.Close()
+}
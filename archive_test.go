@@ -0,0 +1,97 @@
+package pkg_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/olebeck/go-pkg"
+)
+
+func TestWriteArchive(t *testing.T) {
+	p := testHomebrew(t)
+	opts := pkg.InstallOptions{License: []byte("rif")}
+	want := []string{
+		"app/VITA00001/eboot.bin",
+		"app/VITA00001/sce_sys/icon0.png",
+		"app/VITA00001/sce_sys/package/head.bin",
+		"app/VITA00001/sce_sys/package/work.bin",
+		"license/app/VITA00001/EP0000-VITA00001_00-0000000000000000.rif",
+	}
+
+	var zbuf bytes.Buffer
+	if err := pkg.WriteZip(&zbuf, p, opts); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(zbuf.Bytes()), int64(zbuf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range want {
+		f, err := zr.Open(name)
+		if err != nil {
+			t.Errorf("zip: %v", err)
+			continue
+		}
+		f.Close()
+	}
+
+	var tbuf bytes.Buffer
+	if err := pkg.WriteTar(&tbuf, p, opts); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{}
+	tr := tar.NewReader(&tbuf)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[h.Name], _ = io.ReadAll(tr)
+	}
+	for _, name := range want {
+		if _, ok := files[name]; !ok {
+			t.Errorf("tar: missing %s", name)
+		}
+	}
+	if got := files["app/VITA00001/eboot.bin"]; !bytes.Equal(got, bytes.Repeat([]byte("elf "), 100)) {
+		t.Errorf("tar: eboot.bin content mismatch")
+	}
+}
+
+// failingReaderAt fails every read reaching into the last bytes of r
+type failingReaderAt struct {
+	r    *bytes.Reader
+	from int64
+}
+
+func (f failingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off+int64(len(p)) > f.from {
+		return 0, errors.New("read failed")
+	}
+	return f.r.ReadAt(p, off)
+}
+
+func TestWriteArchiveItemError(t *testing.T) {
+	// eboot.bin is the last item, its data ends right before the 0x20 byte tail
+	data := pkg.BuildTestPkg("EP0000-VITA00001_00-0000000000000000", 0x15, nil, []pkg.TestItem{
+		{Name: "eboot.bin", Data: bytes.Repeat([]byte("elf "), 100)},
+	})
+	p, err := pkg.ReadWithOptions(failingReaderAt{bytes.NewReader(data), int64(len(data) - 0x40)}, pkg.ReadOptions{Size: int64(len(data))})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, write := range map[string]func(io.Writer, *pkg.Pkg, pkg.InstallOptions) error{"zip": pkg.WriteZip, "tar": pkg.WriteTar} {
+		var itemErr *pkg.ItemError
+		if err := write(io.Discard, p, pkg.InstallOptions{}); !errors.As(err, &itemErr) || itemErr.Name != "eboot.bin" {
+			t.Errorf("%s: err = %v, want *ItemError for eboot.bin", name, err)
+		}
+	}
+}
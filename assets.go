@@ -0,0 +1,154 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+
+	"github.com/olebeck/go-pkg/livearea"
+	"github.com/olebeck/go-pkg/pbp"
+)
+
+const liveAreaDir = "sce_sys/livearea/contents/"
+
+// Asset is an image or other file read from the package, possibly from inside EBOOT.PBP
+type Asset struct {
+	Name string
+	Size int64
+	*io.SectionReader
+}
+
+// Icon returns sce_sys/icon0.png, or ICON0.PNG from EBOOT.PBP for psp packages
+func (p *Pkg) Icon() (*Asset, error) {
+	return p.asset("sce_sys/icon0.png", pbp.Icon0)
+}
+
+// Background returns sce_sys/pic0.png, or PIC1.PNG from EBOOT.PBP for psp packages
+func (p *Pkg) Background() (*Asset, error) {
+	return p.asset("sce_sys/pic0.png", pbp.Pic1)
+}
+
+// LiveAreaAssets returns the files in sce_sys/livearea/contents
+func (p *Pkg) LiveAreaAssets() ([]Asset, error) {
+	items, err := p.Items()
+	if err != nil {
+		return nil, err
+	}
+
+	var assets []Asset
+	for i := range items {
+		item := &items[i]
+		if item.IsDir() || !strings.HasPrefix(strings.ToLower(item.Name), liveAreaDir) {
+			continue
+		}
+		assets = append(assets, item.asset())
+	}
+	return assets, nil
+}
+
+// LiveArea is the parsed template.xml with the images it references
+type LiveArea struct {
+	*livearea.Template
+	// by name as written in the template, images missing from the package are left out
+	Images map[string]Asset
+}
+
+// LiveArea parses sce_sys/livearea/contents/template.xml and looks up the images it references in the package
+func (p *Pkg) LiveArea() (*LiveArea, error) {
+	item, err := p.findItem(liveAreaDir + "template.xml")
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := livearea.Parse(item.sectionReader())
+	if err != nil {
+		return nil, fmt.Errorf("template.xml: %w", err)
+	}
+
+	la := &LiveArea{Template: tmpl, Images: make(map[string]Asset)}
+	for _, name := range tmpl.ImageNames() {
+		clean, err := SafeName(name)
+		if err != nil {
+			continue
+		}
+		if item, err := p.findItem(liveAreaDir + clean); err == nil {
+			la.Images[name] = item.asset()
+		}
+	}
+	return la, nil
+}
+
+// Pbp returns USRDIR/CONTENT/EBOOT.PBP of psp and psx packages
+func (p *Pkg) Pbp() (*pbp.File, error) {
+	if p.PkgType != PKG_TYPE_PSP && p.PkgType != PKG_TYPE_PSX {
+		return nil, fmt.Errorf("package type %d has no EBOOT.PBP", p.PkgType)
+	}
+	eboot, err := p.findItem("USRDIR/CONTENT/EBOOT.PBP")
+	if err != nil {
+		return nil, err
+	}
+	sr := eboot.sectionReader()
+	f, err := pbp.Read(sr, sr.Size())
+	if err != nil {
+		return nil, fmt.Errorf("EBOOT.PBP: %w", err)
+	}
+	return f, nil
+}
+
+func (p *Pkg) asset(name string, section pbp.Section) (*Asset, error) {
+	switch p.PkgType {
+	case PKG_TYPE_PSP, PKG_TYPE_PSX:
+		f, err := p.Pbp()
+		if err != nil {
+			return nil, err
+		}
+		sr := f.Section(section)
+		if sr.Size() == 0 {
+			return nil, &fs.PathError{Op: "open", Path: "EBOOT.PBP/" + section.String(), Err: fs.ErrNotExist}
+		}
+		return &Asset{Name: section.String(), Size: sr.Size(), SectionReader: sr}, nil
+	}
+
+	item, err := p.findItem(name)
+	if err != nil {
+		return nil, err
+	}
+	asset := item.asset()
+	return &asset, nil
+}
+
+// findItem looks up an item by name, ignoring case
+func (p *Pkg) findItem(name string) (*Item, error) {
+	items, err := p.Items()
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		if !items[i].IsDir() && strings.EqualFold(items[i].Name, name) {
+			return &items[i], nil
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+func (item *Item) asset() Asset {
+	return Asset{Name: item.Name, Size: int64(item.Size), SectionReader: item.sectionReader()}
+}
+
+// sectionReader returns an independent reader over the item, only items without ReadAt are read into memory
+func (item *Item) sectionReader() *io.SectionReader {
+	if ra, ok := item.ReadSeeker.(io.ReaderAt); ok {
+		return io.NewSectionReader(ra, 0, int64(item.Size))
+	}
+	item.Seek(0, io.SeekStart)
+	data, err := io.ReadAll(item.ReadSeeker)
+	if err != nil {
+		return io.NewSectionReader(errReaderAt{err}, 0, int64(item.Size))
+	}
+	return io.NewSectionReader(bytes.NewReader(data), 0, int64(len(data)))
+}
+
+type errReaderAt struct{ err error }
+
+func (e errReaderAt) ReadAt([]byte, int64) (int, error) { return 0, e.err }
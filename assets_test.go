@@ -0,0 +1,119 @@
+package pkg_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+
+	"github.com/olebeck/go-pkg"
+)
+
+func readAsset(t *testing.T, a *pkg.Asset) string {
+	t.Helper()
+	data, err := io.ReadAll(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(data)) != a.Size {
+		t.Fatalf("%s: read %d bytes, size %d", a.Name, len(data), a.Size)
+	}
+	return string(data)
+}
+
+func TestAssets(t *testing.T) {
+	p := testHomebrew(t)
+	icon, err := p.Icon()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readAsset(t, icon); got != "png data" {
+		t.Errorf("icon = %q", got)
+	}
+	if _, err := p.Background(); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("background err = %v, want fs.ErrNotExist", err)
+	}
+
+	assets, err := p.LiveAreaAssets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(assets) != 1 || assets[0].Name != "sce_sys/livearea/contents/template.xml" {
+		t.Fatalf("livearea assets = %v", assets)
+	}
+	if got := readAsset(t, &assets[0]); got != "<livearea/>" {
+		t.Errorf("template.xml = %q", got)
+	}
+}
+
+func TestAssetsPSP(t *testing.T) {
+	eboot := pkg.BuildTestPbp(pkg.BuildTestSfo(map[string]string{"TITLE": "psp"}), []byte("icon0"), nil, nil, []byte("pic1"), nil, []byte("data.psp"))
+	data := pkg.BuildTestPkg("UP0000-NPUZ00000_00-0000000000000000", 7, nil, []pkg.TestItem{
+		{Name: "USRDIR", Flags: 4},
+		{Name: "USRDIR/CONTENT", Flags: 4},
+		{Name: "USRDIR/CONTENT/EBOOT.PBP", Data: eboot},
+	})
+	p, err := pkg.Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	icon, err := p.Icon()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readAsset(t, icon); got != "icon0" {
+		t.Errorf("icon = %q", got)
+	}
+	bg, err := p.Background()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readAsset(t, bg); got != "pic1" {
+		t.Errorf("background = %q", got)
+	}
+
+	pbpFile, err := p.Pbp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	param, err := pbpFile.Sfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if title := param.String("TITLE"); title != "psp" {
+		t.Errorf("TITLE = %q", title)
+	}
+}
+
+func TestLiveArea(t *testing.T) {
+	template := `<livearea style="a1" format-ver="01.00" content-rev="1">
+<livearea-background><image>bg0.png</image></livearea-background>
+<gate><startup-image>startup.png</startup-image></gate>
+<frame id="frame1"><liveitem><image>missing.png</image></liveitem></frame>
+</livearea>`
+	data := pkg.BuildTestPkg("EP0000-PCSE00000_00-0000000000000000", 0x15, nil, []pkg.TestItem{
+		{Name: "sce_sys/livearea/contents/template.xml", Data: []byte(template)},
+		{Name: "sce_sys/livearea/contents/bg0.png", Data: []byte("bg")},
+		{Name: "sce_sys/livearea/contents/startup.png", Data: []byte("startup")},
+	})
+	p, err := pkg.Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	la, err := p.LiveArea()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(la.Frames) != 1 || len(la.Images) != 2 {
+		t.Fatalf("frames %d, images %v", len(la.Frames), la.Images)
+	}
+	bg := la.Images["bg0.png"]
+	if got := readAsset(t, &bg); got != "bg" {
+		t.Errorf("bg0.png = %q", got)
+	}
+	if _, ok := la.Images["missing.png"]; ok {
+		t.Error("missing.png resolved")
+	}
+}
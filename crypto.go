@@ -7,23 +7,43 @@ import (
 )
 
 type ctrReader struct {
-	reader  io.ReadSeeker
+	reader  *io.SectionReader
 	block   cipher.Block
 	stream  cipher.Stream
 	ivStart []byte
-	buffer  []byte
 	offset  int64
 }
 
-func newCTR(reader io.ReadSeeker, block cipher.Block, iv []byte) *ctrReader {
+func newCTR(reader *io.SectionReader, block cipher.Block, iv []byte) *ctrReader {
 	return &ctrReader{
 		reader:  reader,
 		block:   block,
 		ivStart: iv,
-		buffer:  make([]byte, block.BlockSize()),
 	}
 }
 
+// ctrIV returns the counter of the block containing offset
+func ctrIV(ivStart []byte, offset uint64) []byte {
+	var iv = make([]byte, len(ivStart))
+	copy(iv, ivStart)
+	incrementCounter(iv, int(offset/uint64(len(ivStart))))
+	return iv
+}
+
+// ctrStreamAt returns a keystream positioned at offset
+func ctrStreamAt(block cipher.Block, ivStart []byte, offset int64) cipher.Stream {
+	stream := cipher.NewCTR(block, ctrIV(ivStart, uint64(offset)))
+	if skip := offset % int64(len(ivStart)); skip > 0 {
+		var discard = make([]byte, skip)
+		stream.XORKeyStream(discard, discard)
+	}
+	return stream
+}
+
+func (r *ctrReader) streamAt(offset int64) cipher.Stream {
+	return ctrStreamAt(r.block, r.ivStart, offset)
+}
+
 func (r *ctrReader) Seek(offset int64, whence int) (int64, error) {
 	var absOffset int64
 	switch whence {
@@ -32,11 +52,7 @@ func (r *ctrReader) Seek(offset int64, whence int) (int64, error) {
 	case io.SeekCurrent:
 		absOffset = r.offset + offset
 	case io.SeekEnd:
-		fileSize, err := r.reader.Seek(0, io.SeekEnd)
-		if err != nil {
-			return 0, err
-		}
-		absOffset = fileSize + offset
+		absOffset = r.reader.Size() + offset
 	default:
 		return 0, errors.New("invalid whence")
 	}
@@ -44,30 +60,45 @@ func (r *ctrReader) Seek(offset int64, whence int) (int64, error) {
 	if absOffset < 0 {
 		return 0, errors.New("negative seek")
 	}
-	r.reader.Seek(absOffset, io.SeekStart)
-
-	blockOffset := absOffset / int64(len(r.ivStart))
-	var iv = make([]byte, len(r.ivStart))
-	copy(iv, r.ivStart)
-	incrementCounter(iv, int(blockOffset))
+	if _, err := r.reader.Seek(absOffset, io.SeekStart); err != nil {
+		return 0, err
+	}
 
-	r.stream = cipher.NewCTR(r.block, iv)
+	r.stream = r.streamAt(absOffset)
+	r.offset = absOffset
 	return absOffset, nil
 }
 
 func (r *ctrReader) Read(data []byte) (int, error) {
 	if r.stream == nil {
-		r.stream = cipher.NewCTR(r.block, r.ivStart)
+		r.stream = r.streamAt(0)
 		r.reader.Seek(0, io.SeekStart)
 	}
 
 	n, err := r.reader.Read(data)
 	if n > 0 {
-		r.stream.XORKeyStream(data, data)
+		r.stream.XORKeyStream(data[:n], data[:n])
+		r.offset += int64(n)
 	}
 	return n, err
 }
 
+// ReadAt decrypts independently of the Read position, so it is safe for concurrent use
+func (r *ctrReader) ReadAt(data []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	n, err := r.reader.ReadAt(data, off)
+	if n > 0 {
+		r.streamAt(off).XORKeyStream(data[:n], data[:n])
+	}
+	return n, err
+}
+
+func (r *ctrReader) Size() int64 {
+	return r.reader.Size()
+}
+
 func incrementCounter(counter []byte, increments int) {
 	carry := uint32(increments)
 	for i := len(counter) - 1; i >= 0 && carry > 0; i-- {
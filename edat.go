@@ -0,0 +1,21 @@
+package pkg
+
+import (
+	"fmt"
+
+	"github.com/olebeck/go-pkg/edat"
+)
+
+// OpenEDAT opens the EDAT, SDAT or PSP EDAT item name for decryption, see edat.Open for when klicensee is needed.
+// PSP EDAT files in psp dlc packages recover their key from the PGD header, pass nil for them.
+func (p *Pkg) OpenEDAT(name string, klicensee []byte) (*edat.File, error) {
+	item, err := p.findItem(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := edat.Open(item.sectionReader(), klicensee)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", item.Name, err)
+	}
+	return f, nil
+}
@@ -0,0 +1,89 @@
+package edat
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+)
+
+// the psp amctrl mac and cipher on top of the kirk engine,
+// kirk commands 4 and 7 are AES-128-CBC with a zero iv, on a single block that is plain AES
+
+func kirk_encrypt(key, b []byte) {
+	c, _ := aes.NewCipher(key)
+	c.Encrypt(b, b)
+}
+
+func kirk_decrypt(key, b []byte) {
+	c, _ := aes.NewCipher(key)
+	c.Decrypt(b, b)
+}
+
+// bbmac is sceDrmBBMacFinal, a cmac with kirk key 0x38 xored with a constant, bound to vkey if given.
+// mac type 2 needs the per console kirk command 5 and is not supported.
+func bbmac(data, vkey []byte) []byte {
+	mac := aes_cmac(kirk_key_38, data)
+	xorBlock(mac, amctrl_loc_1cd4)
+	if vkey != nil {
+		xorBlock(mac, vkey)
+		kirk_encrypt(kirk_key_38, mac)
+	}
+	return mac
+}
+
+// bbmac_stored unwraps a mac as stored in the file, type 3 macs are encrypted with kirk key 0x63
+func bbmac_stored(macType int, stored []byte) []byte {
+	mac := bytes.Clone(stored[:0x10])
+	if macType == 3 {
+		kirk_decrypt(kirk_key_63, mac)
+	}
+	return mac
+}
+
+// bbmac_wrap is the reverse of bbmac_stored
+func bbmac_wrap(macType int, mac []byte) []byte {
+	if macType == 3 {
+		kirk_encrypt(kirk_key_63, mac)
+	}
+	return mac
+}
+
+// bbmac_check is sceDrmBBMacFinal2
+func bbmac_check(macType int, data, stored, vkey []byte) bool {
+	return bytes.Equal(bbmac(data, vkey), bbmac_stored(macType, stored))
+}
+
+// bbmac_getkey recovers the vkey a stored mac of data was made with
+func bbmac_getkey(macType int, data, stored []byte) []byte {
+	vkey := bbmac_stored(macType, stored)
+	kirk_decrypt(kirk_key_38, vkey)
+	xorBlock(vkey, bbmac(data, nil))
+	return vkey
+}
+
+// bbcipher is sceDrmBBCipher type 1, a counter mode keyed by header key and vkey.
+// seed is the offset of data in 16 byte units, en- and decryption are the same.
+func bbcipher(data, headerKey, vkey []byte, seed uint32) {
+	var base [16]byte
+	copy(base[:], headerKey)
+	xorBlock(base[:], vkey)
+	xorBlock(base[:], amctrl_loc_1cf4)
+	kirk_decrypt(kirk_key_39, base[:])
+	xorBlock(base[:], amctrl_loc_1ce4)
+
+	c, _ := aes.NewCipher(kirk_key_63)
+	var ks, prev [16]byte
+	for off := 0; off+16 <= len(data); off += 16 {
+		n := seed + 1 + uint32(off/16)
+		copy(ks[:12], base[:12])
+		binary.LittleEndian.PutUint32(ks[12:], n)
+		c.Decrypt(ks[:], ks[:])
+		// cbc chaining on the counters, the first counter has a zero iv
+		if n != 1 {
+			copy(prev[:12], base[:12])
+			binary.LittleEndian.PutUint32(prev[12:], n-1)
+			xorBlock(ks[:], prev[:])
+		}
+		xorBlock(data[off:off+16], ks[:])
+	}
+}
@@ -0,0 +1,57 @@
+package edat
+
+import "crypto/aes"
+
+// aes_cmac is AES-CMAC (RFC 4493)
+func aes_cmac(key, data []byte) []byte {
+	block, _ := aes.NewCipher(key)
+
+	var k1, k2 [16]byte
+	block.Encrypt(k1[:], k1[:])
+	cmac_subkey(k1[:])
+	k2 = k1
+	cmac_subkey(k2[:])
+
+	n := (len(data) + 15) / 16
+	complete := n > 0 && len(data)%16 == 0
+	if n == 0 {
+		n = 1
+	}
+
+	var x [16]byte
+	for i := 0; i < n-1; i++ {
+		xorBlock(x[:], data[i*16:i*16+16])
+		block.Encrypt(x[:], x[:])
+	}
+
+	var last [16]byte
+	rest := data[(n-1)*16:]
+	copy(last[:], rest)
+	if complete {
+		xorBlock(last[:], k1[:])
+	} else {
+		last[len(rest)] = 0x80
+		xorBlock(last[:], k2[:])
+	}
+	xorBlock(x[:], last[:])
+	block.Encrypt(x[:], x[:])
+	return x[:]
+}
+
+// cmac_subkey doubles k in GF(2^128)
+func cmac_subkey(k []byte) {
+	msb := k[0] & 0x80
+	for i := 0; i < 15; i++ {
+		k[i] = k[i]<<1 | k[i+1]>>7
+	}
+	k[15] <<= 1
+	if msb != 0 {
+		k[15] ^= 0x87
+	}
+}
+
+func xorBlock(dst, src []byte) {
+	for i := range dst {
+		dst[i] ^= src[i]
+	}
+}
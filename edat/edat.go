@@ -0,0 +1,274 @@
+// Package edat decrypts npdrm EDAT and SDAT files.
+//
+// PSP EDAT files ("\x00PSPEDAT") and raw PGD data are decrypted with the psp amctrl functions,
+// their key is recovered from the PGD header so no klicensee is needed.
+package edat
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	ErrNoKey        = errors.New("edat: klicensee required")
+	ErrHashMismatch = errors.New("edat: block hash mismatch")
+	ErrCompressed   = errors.New("edat: compressed files are not supported")
+	ErrPGD          = errors.New("edat: PGD type needs console keys, not supported")
+)
+
+const (
+	flagCompressed   = 0x00000001
+	flagPlain        = 0x00000002 // data is not encrypted
+	flagEncryptedKey = 0x00000008
+	flagHashKey      = 0x00000010
+	flagInterleaved  = 0x00000020 // metadata precedes every block, 0x14 byte hmac
+	flagSdat         = 0x01000000
+	flagDebug        = 0x80000000
+)
+
+const (
+	headerSize     = 0x100
+	maxBlockSize   = 1 << 20
+	licenseMask    = 3
+	licenseFree    = 3
+	pspEdatMagic   = "\x00PSPEDAT"
+	pgdMagic       = "\x00PGD"
+	pspEdatPgdOffs = 0x90
+)
+
+type NPD struct {
+	Magic     [4]byte
+	Version   uint32
+	License   uint32
+	Type      uint32
+	ContentID [0x30]byte
+	Digest    [0x10]byte
+	TitleHash [0x10]byte
+	DevHash   [0x10]byte
+	Unk1      uint64
+	Unk2      uint64
+}
+
+type Header struct {
+	Flags     uint32
+	BlockSize uint32
+	FileSize  uint64
+}
+
+// File is an opened EDAT, SDAT or PGD file, it decrypts blocks on demand
+type File struct {
+	NPD    NPD
+	Header Header
+	PGD    *PGD // set for PSP EDAT and PGD files, which have no NPD
+
+	r   io.ReaderAt
+	key []byte
+	pgd *pgdKeys
+}
+
+// Open reads the header of an EDAT, SDAT or PGD file.
+// klicensee is required for EDAT files unless they have a free license, SDAT files derive their key from the header.
+// For PSP EDAT and PGD files klicensee is the optional version key, without it the key is recovered from the header.
+func Open(r io.ReaderAt, klicensee []byte) (*File, error) {
+	var header [headerSize]byte
+	n, err := r.ReadAt(header[:], 0)
+	if n >= 8 && (string(header[:8]) == pspEdatMagic || string(header[:4]) == pgdMagic) {
+		return openPGD(r, klicensee)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	f := &File{r: r}
+	rd := bytes.NewReader(header[:])
+	binary.Read(rd, binary.BigEndian, &f.NPD)
+	binary.Read(rd, binary.BigEndian, &f.Header)
+
+	if string(f.NPD.Magic[:]) != "NPD\x00" {
+		return nil, errors.New("edat: not a npd file")
+	}
+	if f.NPD.Version < 1 || f.NPD.Version > 4 {
+		return nil, fmt.Errorf("edat: unsupported version %d", f.NPD.Version)
+	}
+	if f.Header.BlockSize == 0 || f.Header.BlockSize > maxBlockSize || f.Header.BlockSize%16 != 0 {
+		return nil, fmt.Errorf("edat: invalid block size 0x%x", f.Header.BlockSize)
+	}
+	if f.Header.Flags&flagCompressed != 0 {
+		return nil, ErrCompressed
+	}
+
+	switch {
+	case f.IsSDAT():
+		f.key = bytes.Clone(sdat_key)
+		xorBlock(f.key, f.NPD.DevHash[:])
+	case klicensee != nil:
+		if len(klicensee) != 16 {
+			return nil, fmt.Errorf("edat: invalid klicensee length %d", len(klicensee))
+		}
+		f.key = bytes.Clone(klicensee)
+	case f.NPD.License&licenseMask == licenseFree:
+		f.key = np_klic_free
+	default:
+		return nil, ErrNoKey
+	}
+	return f, nil
+}
+
+func (f *File) IsSDAT() bool {
+	return f.Header.Flags&flagSdat != 0
+}
+
+// Size is the size of the decrypted data
+func (f *File) Size() int64 {
+	return int64(f.Header.FileSize)
+}
+
+func (f *File) NumBlocks() int {
+	bs := uint64(f.Header.BlockSize)
+	return int((f.Header.FileSize + bs - 1) / bs)
+}
+
+// blockKeys derives the data and hash key of block i
+func (f *File) blockKeys(i int) (key, hashKey []byte) {
+	var b [16]byte
+	if f.NPD.Version > 1 {
+		copy(b[:12], f.NPD.DevHash[:12])
+	}
+	binary.BigEndian.PutUint32(b[12:], uint32(i))
+
+	c, _ := aes.NewCipher(f.key)
+	key = make([]byte, 16)
+	c.Encrypt(key, b[:])
+	hashKey = bytes.Clone(key)
+	if f.Header.Flags&flagHashKey != 0 {
+		c.Encrypt(hashKey, key)
+	}
+
+	if f.Header.Flags&flagEncryptedKey != 0 {
+		edatKey := edat_key_0
+		if f.NPD.Version == 4 {
+			edatKey = edat_key_1
+		}
+		e, _ := aes.NewCipher(edatKey)
+		e.Decrypt(key, key)
+		e.Decrypt(hashKey, hashKey)
+	}
+	return key, hashKey
+}
+
+// blockLocation returns where the data and metadata of block i are, and the unpadded data length
+func (f *File) blockLocation(i int) (dataOffset, metaOffset int64, metaSize, length int) {
+	bs := int64(f.Header.BlockSize)
+	length = int(bs)
+	if rest := int64(f.Header.FileSize) - int64(i)*bs; rest < bs {
+		length = int(rest)
+	}
+
+	if f.Header.Flags&flagInterleaved != 0 {
+		metaOffset = headerSize + int64(i)*(0x20+bs)
+		return metaOffset + 0x20, metaOffset, 0x20, length
+	}
+	metaOffset = headerSize + int64(i)*0x10
+	return headerSize + int64(f.NumBlocks())*0x10 + int64(i)*bs, metaOffset, 0x10, length
+}
+
+// ReadBlock decrypts block i after checking its hash
+func (f *File) ReadBlock(i int) ([]byte, error) {
+	if i < 0 || i >= f.NumBlocks() {
+		return nil, fmt.Errorf("edat: block %d out of range", i)
+	}
+	if f.pgd != nil {
+		return f.readPGDBlock(i)
+	}
+	dataOffset, metaOffset, metaSize, length := f.blockLocation(i)
+
+	meta := make([]byte, metaSize)
+	if _, err := f.r.ReadAt(meta, metaOffset); err != nil {
+		return nil, fmt.Errorf("edat: block %d metadata: %w", i, err)
+	}
+	expected := meta
+	if metaSize == 0x20 {
+		expected = meta[:0x14]
+		for j := 0; j < 0x10; j++ {
+			expected[j] ^= meta[j+0x10]
+		}
+	}
+
+	data := make([]byte, (length+15)&^15)
+	if _, err := f.r.ReadAt(data, dataOffset); err != nil {
+		return nil, fmt.Errorf("edat: block %d: %w", i, err)
+	}
+	if f.Header.Flags&flagDebug != 0 {
+		return data[:length], nil
+	}
+
+	key, hashKey := f.blockKeys(i)
+	var sum []byte
+	switch {
+	case f.Header.Flags&flagHashKey == 0:
+		sum = aes_cmac(hashKey, data)
+		expected = expected[:0x10]
+	default:
+		h := hmac.New(sha1.New, hashKey)
+		h.Write(data)
+		sum = h.Sum(nil)
+	}
+	if !hmac.Equal(sum[:len(expected)], expected) {
+		return nil, fmt.Errorf("block %d: %w", i, ErrHashMismatch)
+	}
+
+	if f.Header.Flags&flagPlain == 0 {
+		var iv [16]byte
+		if f.NPD.Version > 1 {
+			iv = f.NPD.Digest
+		}
+		c, _ := aes.NewCipher(key)
+		cipher.NewCBCDecrypter(c, iv[:]).CryptBlocks(data, data)
+	}
+	return data[:length], nil
+}
+
+// ReadAt reads decrypted data, every block touched is decrypted and checked
+func (f *File) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("edat: negative offset")
+	}
+	bs := int64(f.Header.BlockSize)
+	for n < len(p) {
+		if off >= f.Size() {
+			return n, io.EOF
+		}
+		block, err := f.ReadBlock(int(off / bs))
+		if err != nil {
+			return n, err
+		}
+		c := copy(p[n:], block[off%bs:])
+		n += c
+		off += int64(c)
+	}
+	return n, nil
+}
+
+// WriteTo writes all decrypted data to w
+func (f *File) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for i := 0; i < f.NumBlocks(); i++ {
+		block, err := f.ReadBlock(i)
+		if err != nil {
+			return written, err
+		}
+		n, err := w.Write(block)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
@@ -0,0 +1,205 @@
+package edat_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/olebeck/go-pkg/edat"
+)
+
+var testKlicensee = []byte("0123456789abcdef")
+
+func testData() []byte {
+	data := make([]byte, 0x4000*2+123)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	return data
+}
+
+func TestDecrypt(t *testing.T) {
+	data := testData()
+	for _, tc := range []struct {
+		name    string
+		version uint32
+		license uint32
+		flags   uint32
+	}{
+		{"v1 cmac", 1, 2, 0},
+		{"v2 hmac", 2, 2, 0x10},
+		{"v3 interleaved", 3, 2, 0x3c},
+		{"v4 encrypted key", 4, 2, 0x0c},
+		{"v4 interleaved encrypted key", 4, 2, 0x3c},
+		{"plain", 2, 2, 0x02},
+		{"free license", 3, 3, 0x3c},
+		{"sdat", 4, 0, 0x01000000 | 0x3c},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			file := edat.BuildTestEDAT(data, tc.version, tc.license, tc.flags, 0x4000, testKlicensee)
+
+			var key []byte
+			if tc.license&3 != 3 && tc.flags&0x01000000 == 0 {
+				key = testKlicensee
+			}
+			f, err := edat.Open(bytes.NewReader(file), key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.NumBlocks() != 3 || f.Size() != int64(len(data)) {
+				t.Fatalf("%d blocks, size %d", f.NumBlocks(), f.Size())
+			}
+
+			var buf bytes.Buffer
+			if _, err := f.WriteTo(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), data) {
+				t.Fatal("decrypted data differs")
+			}
+
+			got := make([]byte, 100)
+			if _, err := f.ReadAt(got, 0x4000-50); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data[0x4000-50:0x4000+50]) {
+				t.Error("ReadAt across blocks differs")
+			}
+			if _, err := io.ReadAll(io.NewSectionReader(f, 0, f.Size())); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestDecryptErrors(t *testing.T) {
+	data := testData()
+	file := edat.BuildTestEDAT(data, 3, 2, 0x3c, 0x4000, testKlicensee)
+
+	if _, err := edat.Open(bytes.NewReader(file), nil); !errors.Is(err, edat.ErrNoKey) {
+		t.Errorf("err = %v, want ErrNoKey", err)
+	}
+
+	f, err := edat.Open(bytes.NewReader(file), []byte("fedcba9876543210"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.ReadBlock(0); !errors.Is(err, edat.ErrHashMismatch) {
+		t.Errorf("wrong key: err = %v, want ErrHashMismatch", err)
+	}
+
+	tampered := bytes.Clone(file)
+	tampered[len(tampered)-1] ^= 1
+	f, err = edat.Open(bytes.NewReader(tampered), testKlicensee)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.ReadBlock(0); err != nil {
+		t.Errorf("untouched block: %v", err)
+	}
+	if _, err := f.ReadBlock(2); !errors.Is(err, edat.ErrHashMismatch) {
+		t.Errorf("tampered block: err = %v, want ErrHashMismatch", err)
+	}
+}
+
+func TestPGD(t *testing.T) {
+	file := make([]byte, 0x100)
+	copy(file, "\x00PSPEDAT")
+	copy(file[0x90:], "\x00PGD\x01\x00\x00\x00\x02\x00\x00\x00")
+
+	if _, err := edat.Open(bytes.NewReader(file), nil); !errors.Is(err, edat.ErrPGD) {
+		t.Errorf("err = %v, want ErrPGD", err)
+	}
+	pgd, err := edat.ParsePGD(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pgd.Offset != 0x90 || pgd.KeyIndex != 1 || pgd.DRMType != 2 {
+		t.Errorf("unexpected pgd %+v", pgd)
+	}
+}
+
+func TestDecryptPGD(t *testing.T) {
+	data := testData()
+	vkey := []byte("psp version key!")
+	for _, tc := range []struct {
+		name     string
+		keyIndex uint32
+		pspEdat  bool
+	}{
+		{"pgd", 1, false},
+		{"pgd encrypted mac", 2, false},
+		{"psp edat", 1, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if tc.pspEdat {
+				buf.Write(append([]byte("\x00PSPEDAT"), make([]byte, 0x88)...))
+			}
+			if err := edat.EncryptPGD(&buf, data, vkey, tc.keyIndex, 0x400); err != nil {
+				t.Fatal(err)
+			}
+			file := buf.Bytes()
+
+			for _, key := range [][]byte{nil, vkey} {
+				f, err := edat.Open(bytes.NewReader(file), key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if f.PGD == nil || f.PGD.KeyIndex != tc.keyIndex || f.Size() != int64(len(data)) {
+					t.Fatalf("pgd %+v, size %d", f.PGD, f.Size())
+				}
+				var out bytes.Buffer
+				if _, err := f.WriteTo(&out); err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(out.Bytes(), data) {
+					t.Fatal("decrypted data differs")
+				}
+			}
+
+			if _, err := edat.Open(bytes.NewReader(file), []byte("wrong version ke")); !errors.Is(err, edat.ErrHashMismatch) {
+				t.Errorf("wrong version key: err = %v, want ErrHashMismatch", err)
+			}
+
+			tampered := bytes.Clone(file)
+			tampered[len(file)-len(data)/2] ^= 1
+			f, err := edat.Open(bytes.NewReader(tampered), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := f.WriteTo(io.Discard); !errors.Is(err, edat.ErrHashMismatch) {
+				t.Errorf("tampered block: err = %v, want ErrHashMismatch", err)
+			}
+
+			tampered = bytes.Clone(file)
+			tampered[int(f.PGD.Offset)+0x20] ^= 1
+			if _, err := edat.Open(bytes.NewReader(tampered), nil); !errors.Is(err, edat.ErrHashMismatch) {
+				t.Errorf("tampered header: err = %v, want ErrHashMismatch", err)
+			}
+		})
+	}
+}
+
+func TestCMAC(t *testing.T) {
+	// RFC 4493 test vectors
+	key := []byte{0x2b, 0x7e, 0x15, 0x16, 0x28, 0xae, 0xd2, 0xa6, 0xab, 0xf7, 0x15, 0x88, 0x09, 0xcf, 0x4f, 0x3c}
+	msg := []byte{
+		0x6b, 0xc1, 0xbe, 0xe2, 0x2e, 0x40, 0x9f, 0x96, 0xe9, 0x3d, 0x7e, 0x11, 0x73, 0x93, 0x17, 0x2a,
+		0xae, 0x2d, 0x8a, 0x57, 0x1e, 0x03, 0xac, 0x9c, 0x9e, 0xb7, 0x6f, 0xac, 0x45, 0xaf, 0x8e, 0x51,
+		0x30, 0xc8, 0x1c, 0x46, 0xa3, 0x5c, 0xe4, 0x11,
+	}
+	for _, tc := range []struct {
+		n    int
+		want []byte
+	}{
+		{0, []byte{0xbb, 0x1d, 0x69, 0x29, 0xe9, 0x59, 0x37, 0x28, 0x7f, 0xa3, 0x7d, 0x12, 0x9b, 0x75, 0x67, 0x46}},
+		{16, []byte{0x07, 0x0a, 0x16, 0xb4, 0x6b, 0x4d, 0x41, 0x44, 0xf7, 0x9b, 0xdd, 0x9d, 0xd0, 0x4a, 0x28, 0x7c}},
+		{40, []byte{0xdf, 0xa6, 0x67, 0x47, 0xde, 0x9a, 0xe6, 0x30, 0x30, 0xca, 0x32, 0x61, 0x14, 0x97, 0xc8, 0x27}},
+	} {
+		if got := edat.AesCmac(key, msg[:tc.n]); !bytes.Equal(got, tc.want) {
+			t.Errorf("cmac of %d bytes = %x, want %x", tc.n, got, tc.want)
+		}
+	}
+}
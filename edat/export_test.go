@@ -0,0 +1,85 @@
+package edat
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/binary"
+)
+
+// BuildTestEDAT encrypts data into an EDAT file, klicensee is ignored for SDAT and free licenses
+func BuildTestEDAT(data []byte, version, license, flags, blockSize uint32, klicensee []byte) []byte {
+	f := &File{
+		NPD:    NPD{Version: version, License: license, Type: 0},
+		Header: Header{Flags: flags, BlockSize: blockSize, FileSize: uint64(len(data))},
+	}
+	copy(f.NPD.Magic[:], "NPD\x00")
+	copy(f.NPD.ContentID[:], "UP0000-NPUZ00000_00-0000000000000000")
+	copy(f.NPD.Digest[:], "digest digest di")
+	copy(f.NPD.DevHash[:], "dev hash dev has")
+
+	switch {
+	case flags&flagSdat != 0:
+		f.key = bytes.Clone(sdat_key)
+		xorBlock(f.key, f.NPD.DevHash[:])
+	case license&licenseMask == licenseFree:
+		f.key = np_klic_free
+	default:
+		f.key = klicensee
+	}
+
+	var header bytes.Buffer
+	binary.Write(&header, binary.BigEndian, f.NPD)
+	binary.Write(&header, binary.BigEndian, f.Header)
+	out := make([]byte, headerSize)
+	copy(out, header.Bytes())
+
+	for i := 0; i < f.NumBlocks(); i++ {
+		dataOffset, metaOffset, metaSize, length := f.blockLocation(i)
+		block := make([]byte, (length+15)&^15)
+		copy(block, data[i*int(blockSize):])
+
+		key, hashKey := f.blockKeys(i)
+		if flags&flagPlain == 0 {
+			var iv [16]byte
+			if version > 1 {
+				iv = f.NPD.Digest
+			}
+			c, _ := aes.NewCipher(key)
+			cipher.NewCBCEncrypter(c, iv[:]).CryptBlocks(block, block)
+		}
+
+		var sum []byte
+		if flags&flagHashKey == 0 {
+			sum = aes_cmac(hashKey, block)
+		} else {
+			h := hmac.New(sha1.New, hashKey)
+			h.Write(block)
+			sum = h.Sum(nil)
+		}
+
+		meta := make([]byte, metaSize)
+		if metaSize == 0x20 {
+			// the second half is xored onto the first, its first 4 bytes are the end of the hash
+			copy(meta[0x10:], "\x00\x00\x00\x00pad pad pad ")
+			copy(meta[0x10:0x14], sum[0x10:])
+			for j := 0; j < 0x10; j++ {
+				meta[j] = sum[j] ^ meta[j+0x10]
+			}
+		} else {
+			copy(meta, sum)
+		}
+
+		end := int(dataOffset) + len(block)
+		if end > len(out) {
+			out = append(out, make([]byte, end-len(out))...)
+		}
+		copy(out[metaOffset:], meta)
+		copy(out[dataOffset:], block)
+	}
+	return out
+}
+
+var AesCmac = aes_cmac
@@ -0,0 +1,65 @@
+package edat_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/olebeck/go-pkg/edat"
+)
+
+// katVector is a real EDAT, SDAT or PGD file below testdata with the sha256 of its plaintext,
+// key is the klicensee (version key for PGD), empty for SDAT, free licenses and recovered PGD keys
+type katVector struct {
+	File   string
+	Key    string
+	SHA256 string
+}
+
+// TestKnownAnswer decrypts the files listed in testdata/kat.json, these come from real dumps
+// or make_npdata and are not in the repository
+func TestKnownAnswer(t *testing.T) {
+	manifest, err := os.ReadFile(filepath.Join("testdata", "kat.json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Skip("no testdata/kat.json")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vectors []katVector
+	if err := json.Unmarshal(manifest, &vectors); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range vectors {
+		t.Run(v.File, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", v.File))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var key []byte
+			if v.Key != "" {
+				if key, err = hex.DecodeString(v.Key); err != nil {
+					t.Fatal(err)
+				}
+			}
+			f, err := edat.Open(bytes.NewReader(data), key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			h := sha256.New()
+			if _, err := f.WriteTo(h); err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(h.Sum(nil)); got != v.SHA256 {
+				t.Errorf("plaintext sha256 %s, want %s", got, v.SHA256)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package edat
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const pgdHeaderSize = 0x90
+
+// PGD is the header of PGD protected data
+type PGD struct {
+	Offset     int64 // of the PGD header in the file, 0x90 inside PSP EDAT files
+	KeyIndex   uint32
+	DRMType    uint32
+	DataSize   uint32
+	BlockSize  uint32
+	DataOffset uint32 // relative to the PGD header
+}
+
+// pgdKeys is what openPGD recovers from the header
+type pgdKeys struct {
+	macType int
+	vkey    []byte
+	dkey    []byte
+}
+
+// ParsePGD reads the PGD header of a PSP EDAT file or raw PGD data, the encrypted part is left out
+func ParsePGD(r io.ReaderAt) (*PGD, error) {
+	var magic [8]byte
+	if _, err := r.ReadAt(magic[:], 0); err != nil {
+		return nil, err
+	}
+	pgd := &PGD{}
+	if string(magic[:]) == pspEdatMagic {
+		pgd.Offset = pspEdatPgdOffs
+	}
+
+	var header [12]byte
+	if _, err := r.ReadAt(header[:], pgd.Offset); err != nil {
+		return nil, err
+	}
+	if string(header[:4]) != pgdMagic {
+		return nil, errors.New("edat: not a PGD file")
+	}
+	pgd.KeyIndex = binary.LittleEndian.Uint32(header[4:])
+	pgd.DRMType = binary.LittleEndian.Uint32(header[8:])
+	return pgd, nil
+}
+
+// pgdMacType is the mac and cipher type of drm type 1, other drm types use type 2
+func pgdMacType(keyIndex, drmType uint32) (int, error) {
+	if drmType != 1 {
+		return 0, ErrPGD
+	}
+	if keyIndex > 1 {
+		return 3, nil
+	}
+	return 1, nil
+}
+
+// openPGD checks the header macs, recovers the vkey from the 0x70 mac unless given and decrypts the descriptor
+func openPGD(r io.ReaderAt, vkey []byte) (*File, error) {
+	pgd, err := ParsePGD(r)
+	if err != nil {
+		return nil, err
+	}
+	macType, err := pgdMacType(pgd.KeyIndex, pgd.DRMType)
+	if err != nil {
+		return nil, err
+	}
+	if vkey != nil && len(vkey) != 16 {
+		return nil, fmt.Errorf("edat: invalid version key length %d", len(vkey))
+	}
+
+	header := make([]byte, pgdHeaderSize)
+	if _, err := r.ReadAt(header, pgd.Offset); err != nil {
+		return nil, err
+	}
+	if !bbmac_check(macType, header[:0x80], header[0x80:0x90], dnas_key_1a90) {
+		return nil, fmt.Errorf("PGD header: %w", ErrHashMismatch)
+	}
+	if vkey == nil {
+		vkey = bbmac_getkey(macType, header[:0x70], header[0x70:0x80])
+	} else if !bbmac_check(macType, header[:0x70], header[0x70:0x80], vkey) {
+		return nil, fmt.Errorf("PGD version key: %w", ErrHashMismatch)
+	}
+
+	desc := bytes.Clone(header[0x30:0x60])
+	bbcipher(desc, header[0x10:0x20], vkey, 0)
+	pgd.DataSize = binary.LittleEndian.Uint32(desc[0x14:])
+	pgd.BlockSize = binary.LittleEndian.Uint32(desc[0x18:])
+	pgd.DataOffset = binary.LittleEndian.Uint32(desc[0x1c:])
+	if pgd.BlockSize == 0 || pgd.BlockSize > maxBlockSize || pgd.BlockSize%16 != 0 {
+		return nil, fmt.Errorf("edat: invalid PGD block size 0x%x", pgd.BlockSize)
+	}
+	if pgd.DataOffset < pgdHeaderSize {
+		return nil, fmt.Errorf("edat: PGD data offset 0x%x inside the header", pgd.DataOffset)
+	}
+
+	return &File{
+		Header: Header{BlockSize: pgd.BlockSize, FileSize: uint64(pgd.DataSize)},
+		PGD:    pgd,
+		r:      r,
+		pgd:    &pgdKeys{macType: macType, vkey: vkey, dkey: desc[:0x10]},
+	}, nil
+}
+
+// pgdBlockLocation returns where block i and its mac are, the padded and the unpadded length
+func (f *File) pgdBlockLocation(i int) (dataOffset, macOffset int64, size, length int) {
+	bs := int64(f.PGD.BlockSize)
+	alignSize := (int64(f.PGD.DataSize) + 15) &^ 15
+	data := f.PGD.Offset + int64(f.PGD.DataOffset)
+	size = int(min(bs, alignSize-int64(i)*bs))
+	length = int(min(bs, int64(f.PGD.DataSize)-int64(i)*bs))
+	return data + int64(i)*bs, data + alignSize + int64(i)*0x10, size, length
+}
+
+// readPGDBlock checks the mac of block i in the table after the data and decrypts it
+func (f *File) readPGDBlock(i int) ([]byte, error) {
+	dataOffset, macOffset, size, length := f.pgdBlockLocation(i)
+	mac := make([]byte, 0x10)
+	if _, err := f.r.ReadAt(mac, macOffset); err != nil {
+		return nil, fmt.Errorf("edat: block %d mac: %w", i, err)
+	}
+	data := make([]byte, size)
+	if _, err := f.r.ReadAt(data, dataOffset); err != nil {
+		return nil, fmt.Errorf("edat: block %d: %w", i, err)
+	}
+	if !bbmac_check(f.pgd.macType, data, mac, f.pgd.vkey) {
+		return nil, fmt.Errorf("block %d: %w", i, ErrHashMismatch)
+	}
+	bbcipher(data, f.pgd.dkey, f.pgd.vkey, uint32(int64(i)*int64(f.PGD.BlockSize)>>4))
+	return data[:length], nil
+}
+
+// EncryptPGD writes data as drm type 1 PGD data bound to vkey, a key index over 1 selects the encrypted mac type.
+// PSP EDAT files are the same with a 0x90 byte "\x00PSPEDAT" header in front.
+func EncryptPGD(w io.Writer, data, vkey []byte, keyIndex, blockSize uint32) error {
+	macType, err := pgdMacType(keyIndex, 1)
+	if err != nil {
+		return err
+	}
+	if len(vkey) != 16 {
+		return fmt.Errorf("edat: invalid version key length %d", len(vkey))
+	}
+	if blockSize == 0 || blockSize > maxBlockSize || blockSize%16 != 0 {
+		return fmt.Errorf("edat: invalid block size 0x%x", blockSize)
+	}
+
+	header := make([]byte, pgdHeaderSize)
+	copy(header, pgdMagic)
+	binary.LittleEndian.PutUint32(header[4:], keyIndex)
+	binary.LittleEndian.PutUint32(header[8:], 1)
+	// header key at 0x10, data key at 0x30
+	if _, err := rand.Read(header[0x10:0x20]); err != nil {
+		return err
+	}
+	if _, err := rand.Read(header[0x30:0x40]); err != nil {
+		return err
+	}
+	dkey := bytes.Clone(header[0x30:0x40])
+	binary.LittleEndian.PutUint32(header[0x44:], uint32(len(data)))
+	binary.LittleEndian.PutUint32(header[0x48:], blockSize)
+	binary.LittleEndian.PutUint32(header[0x4c:], pgdHeaderSize)
+	bbcipher(header[0x30:0x60], header[0x10:0x20], vkey, 0)
+
+	enc := make([]byte, (len(data)+15)&^15)
+	copy(enc, data)
+	var table []byte
+	for off := 0; off < len(enc); off += int(blockSize) {
+		block := enc[off:min(off+int(blockSize), len(enc))]
+		bbcipher(block, dkey, vkey, uint32(off>>4))
+		table = append(table, bbmac_wrap(macType, bbmac(block, vkey))...)
+	}
+
+	// the 0x70 mac is bound to vkey, the 0x80 mac covers it with the fixed key
+	copy(header[0x70:], bbmac_wrap(macType, bbmac(header[:0x70], vkey)))
+	copy(header[0x80:], bbmac_wrap(macType, bbmac(header[:0x80], dnas_key_1a90)))
+
+	for _, b := range [][]byte{header, enc, table} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package pkg_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/olebeck/go-pkg"
+	"github.com/olebeck/go-pkg/edat"
+)
+
+func TestOpenEDAT(t *testing.T) {
+	header := make([]byte, 0x100)
+	copy(header, "NPD\x00")
+	binary.BigEndian.PutUint32(header[4:], 3)         // version
+	binary.BigEndian.PutUint32(header[8:], 2)         // license
+	binary.BigEndian.PutUint32(header[0x84:], 0x4000) // block size
+	binary.BigEndian.PutUint64(header[0x88:], 0x8000) // file size
+
+	pspData := bytes.Repeat([]byte("psp dlc data "), 1000)
+	var pspEdat bytes.Buffer
+	pspEdat.Write(append([]byte("\x00PSPEDAT"), make([]byte, 0x88)...))
+	if err := edat.EncryptPGD(&pspEdat, pspData, []byte("psp version key!"), 1, 0x800); err != nil {
+		t.Fatal(err)
+	}
+	tampered := bytes.Clone(pspEdat.Bytes())
+	tampered[0x90+0x90+0x1000] ^= 1
+
+	data := pkg.BuildTestPkg("UP0000-NPUZ00000_00-DLC0000000000001", 7, nil, []pkg.TestItem{
+		{Name: "USRDIR/CONTENT/DATA.EDAT", Data: header},
+		{Name: "USRDIR/CONTENT/PSP.EDAT", Data: pspEdat.Bytes()},
+		{Name: "USRDIR/CONTENT/BROKEN.EDAT", Data: tampered},
+	})
+	p, err := pkg.Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := p.OpenEDAT("USRDIR/CONTENT/DATA.EDAT", nil); !errors.Is(err, edat.ErrNoKey) {
+		t.Errorf("err = %v, want ErrNoKey", err)
+	}
+	f, err := p.OpenEDAT("USRDIR/CONTENT/DATA.EDAT", []byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.NumBlocks() != 2 {
+		t.Errorf("%d blocks", f.NumBlocks())
+	}
+
+	pspFile, err := p.OpenEDAT("USRDIR/CONTENT/PSP.EDAT", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got bytes.Buffer
+	if _, err := pspFile.WriteTo(&got); err != nil || !bytes.Equal(got.Bytes(), pspData) {
+		t.Errorf("PSP.EDAT: %d bytes, %v", got.Len(), err)
+	}
+	broken, err := p.OpenEDAT("USRDIR/CONTENT/BROKEN.EDAT", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := broken.ReadBlock(0); err != nil {
+		t.Errorf("block 0: %v", err)
+	}
+	if _, err := broken.ReadBlock(2); !errors.Is(err, edat.ErrHashMismatch) {
+		t.Errorf("block 2: err = %v, want ErrHashMismatch", err)
+	}
+}
@@ -0,0 +1,161 @@
+package pkg
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/binary"
+	"sort"
+
+	"github.com/olebeck/go-pkg/internal/pbptest"
+)
+
+type TestItem struct {
+	Name  string
+	Data  []byte
+	Flags int
+}
+
+// BuildTestPkg builds a key type 2 package (key type 1 for psp content types) with the given items and optional param.sfo
+func BuildTestPkg(contentID string, contentType uint32, paramSfo []byte, items []TestItem) []byte {
+	return BuildTestPkgKeyType(contentID, contentType, 2, paramSfo, items)
+}
+
+// BuildTestPkgKeyType is BuildTestPkg with the vita key type 2, 3 or 4, psp content types always use key type 1
+func BuildTestPkgKeyType(contentID string, contentType uint32, keyType byte, paramSfo []byte, items []TestItem) []byte {
+	const metaOffset = 0x100
+
+	var meta []byte
+	appendMeta := func(typ uint32, vals ...uint32) {
+		meta = binary.BigEndian.AppendUint32(meta, typ)
+		meta = binary.BigEndian.AppendUint32(meta, uint32(4*len(vals)))
+		for _, v := range vals {
+			meta = binary.BigEndian.AppendUint32(meta, v)
+		}
+	}
+	// item table and sfo offsets are patched in below
+	appendMeta(2, contentType)
+	appendMeta(13, 0, 0)
+	appendMeta(14, 0, 0)
+
+	sfoOffset := align(metaOffset+len(meta), 16)
+	encOffset := align(sfoOffset+len(paramSfo), 16)
+
+	// item table, names, then data
+	var names []byte
+	nameOffsets := make([]int, len(items))
+	tableSize := 32 * len(items)
+	for i, item := range items {
+		nameOffsets[i] = tableSize + len(names)
+		names = append(names, item.Name...)
+		names = append(names, make([]byte, align(len(names), 16)-len(names))...)
+	}
+	itemSize := align(tableSize+len(names), 16)
+
+	enc := make([]byte, itemSize)
+	copy(enc[tableSize:], names)
+	dataOffsets := make([]int, len(items))
+	for i, item := range items {
+		dataOffset := len(enc)
+		dataOffsets[i] = dataOffset
+		enc = append(enc, item.Data...)
+		enc = append(enc, make([]byte, align(len(enc), 16)-len(enc))...)
+
+		e := enc[32*i:]
+		binary.BigEndian.PutUint32(e[0:], uint32(nameOffsets[i]))
+		binary.BigEndian.PutUint32(e[4:], uint32(len(item.Name)))
+		binary.BigEndian.PutUint64(e[8:], uint64(dataOffset))
+		binary.BigEndian.PutUint64(e[16:], uint64(len(item.Data)))
+		e[27] = byte(item.Flags)
+	}
+
+	binary.BigEndian.PutUint32(meta[12+8:], uint32(0))
+	binary.BigEndian.PutUint32(meta[12+12:], uint32(itemSize))
+	binary.BigEndian.PutUint32(meta[28+8:], uint32(sfoOffset))
+	binary.BigEndian.PutUint32(meta[28+12:], uint32(len(paramSfo)))
+
+	iv := []byte("0123456789abcdef")
+	mainKey := make([]byte, 16)
+	map[byte]cipher.Block{2: key_pkg_vita_2, 3: key_pkg_vita_3, 4: key_pkg_vita_4}[keyType].Encrypt(mainKey, iv)
+	switch contentType {
+	case 6, 7, 0xe, 0xf:
+		// psp packages are key type 1, item data uses the fixed ps3 key
+		mainKey, keyType = key_pkg_psp_key, 1
+		cipher.NewCTR(mustAes(mainKey), iv).XORKeyStream(enc[:itemSize], enc[:itemSize])
+		for i, item := range items {
+			data := enc[dataOffsets[i] : dataOffsets[i]+len(item.Data)]
+			cipher.NewCTR(mustAes(key_pkg_ps3_key), ctrIV(iv, uint64(dataOffsets[i]))).XORKeyStream(data, data)
+		}
+	default:
+		cipher.NewCTR(mustAes(mainKey), iv).XORKeyStream(enc, enc)
+	}
+
+	tail := make([]byte, 0x20)
+	totalSize := encOffset + len(enc) + len(tail)
+
+	out := make([]byte, totalSize)
+	h := out
+	copy(h[0:4], "\x7fPKG")
+	binary.BigEndian.PutUint16(h[4:], 0x8000)
+	binary.BigEndian.PutUint16(h[6:], 2)
+	binary.BigEndian.PutUint32(h[8:], metaOffset)
+	binary.BigEndian.PutUint32(h[12:], 3)
+	binary.BigEndian.PutUint32(h[16:], uint32(len(meta)))
+	binary.BigEndian.PutUint32(h[20:], uint32(len(items)))
+	binary.BigEndian.PutUint64(h[24:], uint64(totalSize))
+	binary.BigEndian.PutUint64(h[32:], uint64(encOffset))
+	binary.BigEndian.PutUint64(h[40:], uint64(len(enc)))
+	copy(h[48:48+0x24], contentID)
+	copy(h[112:128], iv)
+	h[231] = keyType
+
+	copy(out[metaOffset:], meta)
+	copy(out[sfoOffset:], paramSfo)
+	copy(out[encOffset:], enc)
+	copy(out[encOffset+len(enc):], bytes.Repeat([]byte{0xAA}, len(tail)))
+	return out
+}
+
+// BuildTestSfo builds a param.sfo with utf8 values
+func BuildTestSfo(values map[string]string) []byte {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var keyTable, dataTable, index []byte
+	for _, k := range keys {
+		v := append([]byte(values[k]), 0)
+		maxLen := align(len(v), 4)
+		index = binary.LittleEndian.AppendUint16(index, uint16(len(keyTable)))
+		index = binary.LittleEndian.AppendUint16(index, 0x0204)
+		index = binary.LittleEndian.AppendUint32(index, uint32(len(v)))
+		index = binary.LittleEndian.AppendUint32(index, uint32(maxLen))
+		index = binary.LittleEndian.AppendUint32(index, uint32(len(dataTable)))
+		keyTable = append(keyTable, k...)
+		keyTable = append(keyTable, 0)
+		dataTable = append(dataTable, v...)
+		dataTable = append(dataTable, make([]byte, maxLen-len(v))...)
+	}
+	keyTable = append(keyTable, make([]byte, align(len(keyTable), 4)-len(keyTable))...)
+
+	var out []byte
+	out = append(out, "\x00PSF"...)
+	out = binary.LittleEndian.AppendUint32(out, 0x0101)
+	out = binary.LittleEndian.AppendUint32(out, uint32(20+len(index)))
+	out = binary.LittleEndian.AppendUint32(out, uint32(20+len(index)+len(keyTable)))
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(keys)))
+	out = append(out, index...)
+	out = append(out, keyTable...)
+	out = append(out, dataTable...)
+	return out
+}
+
+func align(n, a int) int {
+	return (n + a - 1) / a * a
+}
+
+// BuildTestPbp builds an EBOOT.PBP from sections in pbp order (PARAM.SFO, ICON0.PNG, ..., DATA.PSAR)
+func BuildTestPbp(sections ...[]byte) []byte {
+	return pbptest.Build(sections...)
+}
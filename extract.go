@@ -0,0 +1,372 @@
+package pkg
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrUnsafePath    = errors.New("unsafe path")
+	ErrDuplicateItem = errors.New("duplicate item")
+	ErrSymlink       = errors.New("refusing to follow symlink")
+)
+
+// ItemError is returned when extracting an item fails
+type ItemError struct {
+	Name string
+	Err  error
+}
+
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("item %q: %v", e.Name, e.Err)
+}
+
+func (e *ItemError) Unwrap() error {
+	return e.Err
+}
+
+// SafeName normalizes an item name to a clean relative slash separated path.
+// Absolute names, .. components, NUL bytes, backslashes and colons are rejected with ErrUnsafePath.
+func SafeName(name string) (string, error) {
+	if strings.ContainsAny(name, "\x00\\:") {
+		return "", fmt.Errorf("%w: invalid character", ErrUnsafePath)
+	}
+	if strings.HasPrefix(name, "/") {
+		return "", fmt.Errorf("%w: absolute path", ErrUnsafePath)
+	}
+
+	var parts []string
+	for _, part := range strings.Split(name, "/") {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			return "", fmt.Errorf("%w: parent directory", ErrUnsafePath)
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) == 0 {
+		return "", fmt.Errorf("%w: empty name", ErrUnsafePath)
+	}
+	return strings.Join(parts, "/"), nil
+}
+
+// ExtractItems writes items below dst by name.
+// Unsafe names, duplicates and symlinks already present below dst fail with an *ItemError naming the item.
+func ExtractItems(dst string, items []Item) error {
+	seen := make(map[string]bool)
+	for _, item := range items {
+		name, err := SafeName(item.Name)
+		if err != nil {
+			return &ItemError{Name: item.Name, Err: err}
+		}
+		if seen[name] {
+			return &ItemError{Name: item.Name, Err: ErrDuplicateItem}
+		}
+		seen[name] = true
+
+		if err := writeItem(dst, name, item); err != nil {
+			return &ItemError{Name: item.Name, Err: err}
+		}
+	}
+	return nil
+}
+
+// writeItem writes an item to root/name, name has to be safe
+func writeItem(root, name string, item Item) error {
+	if item.IsDir() {
+		return safeMkdirAll(root, name)
+	}
+
+	f, err := safeCreate(root, name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, item.newReader())
+	return errors.Join(err, f.Close())
+}
+
+// safeMkdirAll creates root/name without following symlinks below root
+func safeMkdirAll(root, name string) error {
+	if err := os.MkdirAll(root, 0777); err != nil {
+		return err
+	}
+
+	if name == "" {
+		return nil
+	}
+
+	dir := root
+	for _, part := range strings.Split(name, "/") {
+		dir = filepath.Join(dir, part)
+		st, err := os.Lstat(dir)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			if err := os.Mkdir(dir, 0777); err != nil && !errors.Is(err, fs.ErrExist) {
+				return err
+			}
+		case err != nil:
+			return err
+		case st.Mode()&fs.ModeSymlink != 0:
+			return fmt.Errorf("%w: %s", ErrSymlink, dir)
+		case !st.IsDir():
+			return fmt.Errorf("%s is not a directory", dir)
+		}
+	}
+	return nil
+}
+
+// safeCreate creates or truncates root/name without following symlinks below root
+func safeCreate(root, name string) (*os.File, error) {
+	dir, file := "", name
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		dir, file = name[:i], name[i+1:]
+	}
+	if dir != "" {
+		if err := safeMkdirAll(root, dir); err != nil {
+			return nil, err
+		}
+	} else if err := os.MkdirAll(root, 0777); err != nil {
+		return nil, err
+	}
+
+	dst := filepath.Join(root, filepath.FromSlash(dir), file)
+	if st, err := os.Lstat(dst); err == nil && st.Mode()&fs.ModeSymlink != 0 {
+		return nil, fmt.Errorf("%w: %s", ErrSymlink, dst)
+	}
+	return os.Create(dst)
+}
+
+// Progress is passed to ExtractOptions.OnProgress
+type Progress struct {
+	Items      int // finished items
+	Skipped    int // finished items that were already extracted, see ExtractOptions.Resume
+	TotalItems int
+	Bytes      int64 // written bytes
+	TotalBytes int64
+	Item       string // item that made progress
+}
+
+type ExtractOptions struct {
+	// number of items extracted at once, defaults to runtime.NumCPU()
+	Workers int
+	// only items it returns true for are extracted, nil extracts everything
+	Filter func(Item) bool
+	// called after every written chunk and finished item, never concurrently
+	OnProgress func(Progress)
+	// record extracted items in ExtractManifestName inside dst
+	// and skip items whose file still matches it, for continuing an interrupted extraction
+	Resume bool
+}
+
+// Extract writes the package items below dst as they are named in the package.
+// Items are extracted concurrently, each through its own reader.
+// Files are written to a temporary name first so cancelling or failing never leaves a partial file behind.
+func Extract(ctx context.Context, p *Pkg, dst string, opts ExtractOptions) error {
+	items, err := p.Items()
+	if err != nil {
+		return err
+	}
+
+	var dirs, files []extractFile
+	var totalBytes int64
+	seen := make(map[string]bool)
+	for _, item := range items {
+		if opts.Filter != nil && !opts.Filter(item) {
+			continue
+		}
+		name, err := SafeName(item.Name)
+		if err != nil {
+			return &ItemError{Name: item.Name, Err: err}
+		}
+		if seen[name] {
+			return &ItemError{Name: item.Name, Err: ErrDuplicateItem}
+		}
+		seen[name] = true
+		if opts.Resume && name == ExtractManifestName {
+			return &ItemError{Name: item.Name, Err: fmt.Errorf("%w: reserved name", ErrUnsafePath)}
+		}
+
+		if item.IsDir() {
+			dirs = append(dirs, extractFile{name, item})
+		} else {
+			files = append(files, extractFile{name, item})
+			totalBytes += int64(item.Size)
+		}
+	}
+
+	// directories first so workers never race creating them
+	for _, dir := range dirs {
+		if err := safeMkdirAll(dst, dir.name); err != nil {
+			return &ItemError{Name: dir.Name, Err: err}
+		}
+	}
+
+	var manifest *extractManifest
+	if opts.Resume {
+		if err := os.MkdirAll(dst, 0777); err != nil {
+			return err
+		}
+		manifest, err = openManifest(dst, p.ContentID)
+		if err != nil {
+			return err
+		}
+		defer manifest.Close()
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	progress := &extractProgress{
+		fn:       opts.OnProgress,
+		Progress: Progress{Items: len(dirs), TotalItems: len(dirs) + len(files), TotalBytes: totalBytes},
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	work := make(chan extractFile)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range work {
+				if manifest.extracted(dst, file) {
+					progress.skip(file.Name, int64(file.Size))
+					continue
+				}
+				if err := extractItem(ctx, dst, file, progress, manifest); err != nil {
+					cancel(&ItemError{Name: file.Name, Err: err})
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, file := range files {
+		select {
+		case work <- file:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	return context.Cause(ctx)
+}
+
+type extractFile struct {
+	name string // safe name
+	Item
+}
+
+type extractProgress struct {
+	mu sync.Mutex
+	fn func(Progress)
+	Progress
+}
+
+func (e *extractProgress) skip(name string, size int64) {
+	if e.fn == nil {
+		return
+	}
+	e.mu.Lock()
+	e.Skipped++
+	e.mu.Unlock()
+	e.add(name, size, true)
+}
+
+func (e *extractProgress) add(name string, n int64, done bool) {
+	if e.fn == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.Bytes += n
+	if done {
+		e.Items++
+	}
+	e.Item = name
+	e.fn(e.Progress)
+}
+
+const extractChunkSize = 256 << 10
+
+// extractItem writes one file through a temporary file, removing it again on error.
+// The written file is recorded in manifest if it is not nil.
+func extractItem(ctx context.Context, root string, file extractFile, progress *extractProgress, manifest *extractManifest) (err error) {
+	dir, base := "", file.name
+	if i := strings.LastIndexByte(file.name, '/'); i >= 0 {
+		dir, base = file.name[:i], file.name[i+1:]
+	}
+	if err := safeMkdirAll(root, dir); err != nil {
+		return err
+	}
+	dir = filepath.Join(root, filepath.FromSlash(dir))
+
+	f, err := os.CreateTemp(dir, "."+base+".*.part")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	h := sha256.New()
+	var w io.Writer = f
+	if manifest != nil {
+		w = io.MultiWriter(f, h)
+	}
+
+	r := file.newReader()
+	buf := make([]byte, extractChunkSize)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, rerr := r.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return err
+			}
+			progress.add(file.Name, int64(n), false)
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return rerr
+		}
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+	// rename replaces an existing symlink instead of writing through it
+	if err := os.Rename(f.Name(), filepath.Join(dir, base)); err != nil {
+		return err
+	}
+	if manifest != nil {
+		if err := manifest.record(file.name, int64(file.Size), h.Sum(nil)); err != nil {
+			return err
+		}
+	}
+	progress.add(file.Name, 0, true)
+	return nil
+}
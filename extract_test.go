@@ -0,0 +1,285 @@
+package pkg_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/olebeck/go-pkg"
+)
+
+func TestSafeName(t *testing.T) {
+	for name, want := range map[string]string{
+		"eboot.bin":            "eboot.bin",
+		"./sce_sys//icon0.png": "sce_sys/icon0.png",
+		"a/./b/":               "a/b",
+		"../eboot.bin":         "",
+		"a/../../b":            "",
+		"/etc/passwd":          "",
+		"a\\..\\b":             "",
+		"C:/windows":           "",
+		"a\x00b":               "",
+		"":                     "",
+		"./":                   "",
+	} {
+		got, err := pkg.SafeName(name)
+		if want == "" {
+			if !errors.Is(err, pkg.ErrUnsafePath) {
+				t.Errorf("SafeName(%q) = %q, %v, want ErrUnsafePath", name, got, err)
+			}
+			continue
+		}
+		if err != nil || got != want {
+			t.Errorf("SafeName(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+}
+
+func extractTestPkg(t *testing.T, items []pkg.TestItem) []pkg.Item {
+	t.Helper()
+	data := pkg.BuildTestPkg("EP0000-PCSE00000_00-0000000000000000", 0x15, nil, items)
+	p, err := pkg.Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkgItems, err := p.Items()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkgItems
+}
+
+func TestExtractItems(t *testing.T) {
+	dst := t.TempDir()
+	items := extractTestPkg(t, []pkg.TestItem{{Name: "sce_sys", Flags: 4}, {Name: "sce_sys/icon0.png", Data: []byte("png")}})
+	if err := pkg.ExtractItems(dst, items); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dst, "sce_sys", "icon0.png"))
+	if err != nil || string(data) != "png" {
+		t.Fatalf("icon0.png = %q, %v", data, err)
+	}
+}
+
+func TestExtractItemsUnsafe(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		items []pkg.TestItem
+		bad   string
+		err   error
+	}{
+		{"traversal", []pkg.TestItem{{Name: "ok", Data: []byte("a")}, {Name: "../escape", Data: []byte("b")}}, "../escape", pkg.ErrUnsafePath},
+		{"absolute", []pkg.TestItem{{Name: "/escape", Data: []byte("b")}}, "/escape", pkg.ErrUnsafePath},
+		{"duplicate", []pkg.TestItem{{Name: "a/b", Data: []byte("a")}, {Name: "a//b", Data: []byte("b")}}, "a//b", pkg.ErrDuplicateItem},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			dst := filepath.Join(root, "out")
+			err := pkg.ExtractItems(dst, extractTestPkg(t, tc.items))
+			var itemErr *pkg.ItemError
+			if !errors.As(err, &itemErr) || itemErr.Name != tc.bad || !errors.Is(err, tc.err) {
+				t.Fatalf("err = %v, want item error for %q", err, tc.bad)
+			}
+			if _, err := os.Stat(filepath.Join(root, "escape")); !os.IsNotExist(err) {
+				t.Fatal("file written outside of dst")
+			}
+		})
+	}
+}
+
+func TestExtractItemsSymlink(t *testing.T) {
+	root := t.TempDir()
+	outside := filepath.Join(root, "outside")
+	dst := filepath.Join(root, "out")
+	if err := os.Mkdir(outside, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(dst, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dst, "sce_sys")); err != nil {
+		t.Skip(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "eboot.bin"), filepath.Join(dst, "eboot.bin")); err != nil {
+		t.Skip(err)
+	}
+
+	for _, item := range []pkg.TestItem{
+		{Name: "sce_sys/icon0.png", Data: []byte("png")},
+		{Name: "eboot.bin", Data: []byte("elf")},
+	} {
+		err := pkg.ExtractItems(dst, extractTestPkg(t, []pkg.TestItem{item}))
+		if !errors.Is(err, pkg.ErrSymlink) {
+			t.Errorf("%s: err = %v, want ErrSymlink", item.Name, err)
+		}
+	}
+	entries, _ := os.ReadDir(outside)
+	if len(entries) != 0 {
+		t.Fatalf("wrote through symlink: %v", entries)
+	}
+}
+
+func TestInstallUnsafeContentID(t *testing.T) {
+	data := pkg.BuildTestPkg("EP0000-../../../_00-0000000000000000", 0x15, nil, []pkg.TestItem{{Name: "eboot.bin", Data: []byte("elf")}})
+	p, err := pkg.Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pkg.Install(context.Background(), p, t.TempDir(), pkg.InstallOptions{}); !errors.Is(err, pkg.ErrUnsafePath) {
+		t.Fatalf("err = %v, want ErrUnsafePath", err)
+	}
+}
+
+func extractTestItems() []pkg.TestItem {
+	items := []pkg.TestItem{{Name: "sce_sys", Flags: 4}, {Name: "sce_sys/icon0.png", Data: []byte("png")}}
+	for i := 0; i < 8; i++ {
+		items = append(items, pkg.TestItem{Name: fmt.Sprintf("data/%d.bin", i), Data: bytes.Repeat([]byte{byte(i)}, 600<<10)})
+	}
+	return items
+}
+
+func TestExtract(t *testing.T) {
+	items := extractTestItems()
+	p, err := pkg.Read(bytes.NewReader(pkg.BuildTestPkg("EP0000-PCSE00000_00-0000000000000000", 0x15, nil, items)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := t.TempDir()
+	var last pkg.Progress
+	calls := 0
+	err = pkg.Extract(context.Background(), p, dst, pkg.ExtractOptions{
+		Workers: 4,
+		OnProgress: func(pr pkg.Progress) {
+			if pr.Bytes < last.Bytes || pr.Items < last.Items {
+				t.Errorf("progress went backwards: %+v after %+v", pr, last)
+			}
+			last = pr
+			calls++
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var total int64
+	for _, item := range items {
+		total += int64(len(item.Data))
+		if item.Flags == 4 {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dst, filepath.FromSlash(item.Name)))
+		if err != nil || !bytes.Equal(data, item.Data) {
+			t.Errorf("%s: got %d bytes, %v", item.Name, len(data), err)
+		}
+	}
+	if last.Items != len(items) || last.TotalItems != len(items) || last.Bytes != total || last.TotalBytes != total {
+		t.Errorf("final progress %+v, want %d items %d bytes", last, len(items), total)
+	}
+	if calls <= len(items) {
+		t.Errorf("expected per chunk progress, got %d calls", calls)
+	}
+}
+
+func TestExtractCancel(t *testing.T) {
+	p, err := pkg.Read(bytes.NewReader(pkg.BuildTestPkg("EP0000-PCSE00000_00-0000000000000000", 0x15, nil, extractTestItems())))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err = pkg.Extract(ctx, p, dst, pkg.ExtractOptions{
+		Workers: 2,
+		OnProgress: func(pr pkg.Progress) {
+			if pr.Bytes > 0 {
+				cancel()
+			}
+		},
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+
+	filepath.WalkDir(dst, func(path string, d fs.DirEntry, err error) error {
+		if strings.HasSuffix(path, ".part") {
+			t.Errorf("partial file left behind: %s", path)
+		}
+		if !d.IsDir() {
+			if st, _ := d.Info(); st.Size() != 600<<10 && st.Name() != "icon0.png" {
+				t.Errorf("incomplete file %s", path)
+			}
+		}
+		return nil
+	})
+}
+
+func TestExtractResume(t *testing.T) {
+	items := extractTestItems()
+	p, err := pkg.Read(bytes.NewReader(pkg.BuildTestPkg("EP0000-PCSE00000_00-0000000000000000", 0x15, nil, items)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := t.TempDir()
+
+	// interrupt the first run after some data was written
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err = pkg.Extract(ctx, p, dst, pkg.ExtractOptions{
+		Workers: 1,
+		Resume:  true,
+		OnProgress: func(pr pkg.Progress) {
+			if pr.Items >= 4 {
+				cancel()
+			}
+		},
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+
+	// tamper with one finished file
+	if err := os.WriteFile(filepath.Join(dst, "sce_sys", "icon0.png"), []byte("gif"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	var last pkg.Progress
+	err = pkg.Extract(context.Background(), p, dst, pkg.ExtractOptions{
+		Resume:     true,
+		OnProgress: func(pr pkg.Progress) { last = pr },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last.Skipped == 0 || last.Items != len(items) {
+		t.Errorf("progress %+v, expected skipped items", last)
+	}
+	for _, item := range items {
+		if item.Flags == 4 {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dst, filepath.FromSlash(item.Name)))
+		if err != nil || !bytes.Equal(data, item.Data) {
+			t.Errorf("%s: got %d bytes, %v", item.Name, len(data), err)
+		}
+	}
+
+	// everything matches now
+	err = pkg.Extract(context.Background(), p, dst, pkg.ExtractOptions{
+		Resume:     true,
+		OnProgress: func(pr pkg.Progress) { last = pr },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last.Skipped != len(items)-1 {
+		t.Errorf("skipped %d items, want %d", last.Skipped, len(items)-1)
+	}
+}
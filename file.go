@@ -0,0 +1,47 @@
+package pkg
+
+import (
+	"io"
+	"os"
+)
+
+type readerAtCloser interface {
+	io.ReaderAt
+	io.Closer
+}
+
+// File is a package opened from disk, its items stay readable until Close
+type File struct {
+	*Pkg
+	r readerAtCloser
+}
+
+// Open opens a package, memory mapped where supported
+func Open(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	r, err := openReaderAt(f, st.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := Read(r)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	return &File{Pkg: p, r: r}, nil
+}
+
+// Close releases the file, reads from the items fail afterwards
+func (f *File) Close() error {
+	return f.r.Close()
+}
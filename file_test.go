@@ -0,0 +1,61 @@
+package pkg_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/olebeck/go-pkg"
+)
+
+func TestOpen(t *testing.T) {
+	content := bytes.Repeat([]byte("mapped"), 10000)
+	data := pkg.BuildTestPkg("EP0000-PCSE00000_00-0000000000000000", 0x15, nil, []pkg.TestItem{
+		{Name: "a.bin", Data: content},
+		{Name: "b.bin", Data: content[:100]},
+	})
+	name := filepath.Join(t.TempDir(), "test.pkg")
+	if err := os.WriteFile(name, data, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := pkg.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys, err := f.FS()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, n := range []string{"a.bin", "b.bin"} {
+				file, err := fsys.Open(n)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				got, _ := io.ReadAll(file)
+				if !bytes.HasPrefix(content, got) || len(got) < 100 {
+					t.Errorf("%s: content mismatch", n)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file, _ := fsys.Open("b.bin")
+	if _, err := io.ReadAll(file); err == nil {
+		t.Error("read after close succeeded")
+	}
+}
@@ -0,0 +1,63 @@
+package pkg
+
+import (
+	"path"
+	"strings"
+)
+
+// Match returns a filter for ExtractOptions.Filter and FilterFS that selects items matching any of the patterns.
+// Patterns use path.Match syntax per path element, "**" matches any number of directories
+// and a pattern without a slash is matched against the base name, so "*.png" selects png files anywhere.
+func Match(patterns ...string) (func(Item) bool, error) {
+	globs := make([][]string, len(patterns))
+	for i, pattern := range patterns {
+		parts := strings.Split(strings.Trim(pattern, "/"), "/")
+		for _, part := range parts {
+			if _, err := path.Match(part, ""); err != nil {
+				return nil, err
+			}
+		}
+		globs[i] = parts
+	}
+
+	return func(item Item) bool {
+		name, err := SafeName(item.Name)
+		if err != nil {
+			return false
+		}
+		parts := strings.Split(name, "/")
+		for _, glob := range globs {
+			if len(glob) == 1 && glob[0] != "**" {
+				if ok, _ := path.Match(glob[0], parts[len(parts)-1]); ok {
+					return true
+				}
+				continue
+			}
+			if matchParts(glob, parts) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+func matchParts(glob, parts []string) bool {
+	for len(glob) > 0 {
+		if glob[0] == "**" {
+			for i := 0; i <= len(parts); i++ {
+				if matchParts(glob[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(glob[0], parts[0]); !ok {
+			return false
+		}
+		glob, parts = glob[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
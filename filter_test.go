@@ -0,0 +1,82 @@
+package pkg_test
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/olebeck/go-pkg"
+)
+
+func TestMatch(t *testing.T) {
+	names := []string{"sce_sys", "sce_sys/param.sfo", "sce_sys/icon0.png", "sce_sys/livearea/contents/template.xml", "sce_sys/livearea/contents/bg.png", "eboot.bin"}
+	for _, tc := range []struct {
+		patterns []string
+		want     []string
+	}{
+		{[]string{"sce_sys/**"}, names[:5]},
+		{[]string{"*.png"}, []string{"sce_sys/icon0.png", "sce_sys/livearea/contents/bg.png"}},
+		{[]string{"sce_sys/*.png"}, []string{"sce_sys/icon0.png"}},
+		{[]string{"**/contents/*"}, []string{"sce_sys/livearea/contents/template.xml", "sce_sys/livearea/contents/bg.png"}},
+		{[]string{"sce_sys/icon0.png", "eboot.bin"}, []string{"sce_sys/icon0.png", "eboot.bin"}},
+		{[]string{"**"}, names},
+	} {
+		match, err := pkg.Match(tc.patterns...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, name := range names {
+			if match(pkg.Item{Name: name}) {
+				got = append(got, name)
+			}
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("%q matched %q, want %q", tc.patterns, got, tc.want)
+		}
+	}
+
+	if _, err := pkg.Match("sce_sys/["); err == nil {
+		t.Error("expected bad pattern error")
+	}
+}
+
+func TestFilterFS(t *testing.T) {
+	match, err := pkg.Match("*.png", "sce_sys/param.sfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys, err := testHomebrew(t).FilterFS(match)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(fsys, "sce_sys/icon0.png", "sce_sys/param.sfo"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Stat(fsys, "eboot.bin"); err == nil {
+		t.Error("eboot.bin not filtered")
+	}
+	if _, err := fs.Stat(fsys, "sce_sys/livearea"); err == nil {
+		t.Error("empty directory kept")
+	}
+}
+
+func TestExtractFilter(t *testing.T) {
+	dst := t.TempDir()
+	err := pkg.Extract(context.Background(), testHomebrew(t), dst, pkg.ExtractOptions{
+		Filter: func(item pkg.Item) bool { return !item.IsDir() && item.Size < 64 },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "sce_sys", "icon0.png")); err != nil {
+		t.Error(err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "eboot.bin")); err == nil {
+		t.Error("eboot.bin extracted")
+	}
+}
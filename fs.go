@@ -0,0 +1,177 @@
+package pkg
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+type pkgFS struct {
+	files map[string]*Item
+	dirs  map[string][]string
+}
+
+// FS returns a read only view of the package items, files opened from it read independently of each other
+func (p *Pkg) FS() (fs.FS, error) {
+	return p.FilterFS(nil)
+}
+
+// FilterFS is like FS but only contains the items filter returns true for, and the directories leading to them
+func (p *Pkg) FilterFS(filter func(Item) bool) (fs.FS, error) {
+	return p.newFS(filter, nil)
+}
+
+// newFS builds the FS view, rename maps item names to paths in it
+func (p *Pkg) newFS(filter func(Item) bool, rename func(string) string) (*pkgFS, error) {
+	items, err := p.Items()
+	if err != nil {
+		return nil, err
+	}
+
+	f := &pkgFS{
+		files: make(map[string]*Item),
+		dirs:  map[string][]string{".": nil},
+	}
+	for i := range items {
+		item := &items[i]
+		if filter != nil && !filter(*item) {
+			continue
+		}
+		name := item.Name
+		if rename != nil {
+			name = rename(name)
+		}
+		name = path.Clean(strings.TrimPrefix(name, "/"))
+		if !fs.ValidPath(name) || name == "." {
+			continue
+		}
+		if item.IsDir() {
+			f.addDir(name)
+		} else {
+			f.addDir(path.Dir(name))
+			if _, ok := f.files[name]; !ok {
+				f.dirs[path.Dir(name)] = append(f.dirs[path.Dir(name)], name)
+			}
+			f.files[name] = item
+		}
+	}
+	for _, entries := range f.dirs {
+		slices.Sort(entries)
+	}
+	return f, nil
+}
+
+func (f *pkgFS) addDir(name string) {
+	if _, ok := f.dirs[name]; ok {
+		return
+	}
+	parent := path.Dir(name)
+	f.addDir(parent)
+	f.dirs[parent] = append(f.dirs[parent], name)
+	f.dirs[name] = nil
+}
+
+func (f *pkgFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if item, ok := f.files[name]; ok {
+		return &pkgFile{info: fileInfo{name: path.Base(name), size: int64(item.Size)}, r: item.newReader()}, nil
+	}
+	if _, ok := f.dirs[name]; ok {
+		return &pkgDir{fs: f, name: name, info: fileInfo{name: path.Base(name), dir: true}}, nil
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+func (f *pkgFS) stat(name string) fileInfo {
+	if item, ok := f.files[name]; ok {
+		return fileInfo{name: path.Base(name), size: int64(item.Size)}
+	}
+	return fileInfo{name: path.Base(name), dir: true}
+}
+
+// newReader returns a reader with its own position if the item supports ReadAt
+func (p *Item) newReader() io.ReadSeeker {
+	if ra, ok := p.ReadSeeker.(io.ReaderAt); ok {
+		return io.NewSectionReader(ra, 0, int64(p.Size))
+	}
+	p.Seek(0, io.SeekStart)
+	return p.ReadSeeker
+}
+
+type fileInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (fi fileInfo) Name() string       { return fi.name }
+func (fi fileInfo) Size() int64        { return fi.size }
+func (fi fileInfo) ModTime() time.Time { return time.Time{} }
+func (fi fileInfo) IsDir() bool        { return fi.dir }
+func (fi fileInfo) Sys() any           { return nil }
+func (fi fileInfo) Mode() fs.FileMode {
+	if fi.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+func (fi fileInfo) Type() fs.FileMode          { return fi.Mode().Type() }
+func (fi fileInfo) Info() (fs.FileInfo, error) { return fi, nil }
+
+type pkgFile struct {
+	info fileInfo
+	r    io.ReadSeeker
+}
+
+func (f *pkgFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *pkgFile) Read(p []byte) (int, error) { return f.r.Read(p) }
+func (f *pkgFile) Close() error               { return nil }
+
+func (f *pkgFile) Seek(offset int64, whence int) (int64, error) {
+	return f.r.Seek(offset, whence)
+}
+
+func (f *pkgFile) ReadAt(p []byte, off int64) (int, error) {
+	if ra, ok := f.r.(io.ReaderAt); ok {
+		return ra.ReadAt(p, off)
+	}
+	return 0, errors.ErrUnsupported
+}
+
+type pkgDir struct {
+	fs     *pkgFS
+	name   string
+	info   fileInfo
+	offset int
+}
+
+func (d *pkgDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *pkgDir) Close() error               { return nil }
+
+func (d *pkgDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *pkgDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	names := d.fs.dirs[d.name][d.offset:]
+	if n > 0 && len(names) > n {
+		names = names[:n]
+	}
+	if n > 0 && len(names) == 0 {
+		return nil, io.EOF
+	}
+
+	entries := make([]fs.DirEntry, len(names))
+	for i, name := range names {
+		entries[i] = d.fs.stat(name)
+	}
+	d.offset += len(names)
+	return entries, nil
+}
@@ -0,0 +1,32 @@
+package pkg_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/olebeck/go-pkg"
+)
+
+func FuzzRead(f *testing.F) {
+	f.Add(pkg.BuildTestPkg("EP0000-PCSE00000_00-0000000000000000", 0x15, pkg.BuildTestSfo(map[string]string{"TITLE": "fuzz"}), []pkg.TestItem{
+		{Name: "sce_sys", Flags: 4},
+		{Name: "eboot.bin", Data: []byte("elf")},
+	}))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		opts := pkg.ReadOptions{MaxItems: 1000, MaxMetaSize: 0x10000, MaxNameLength: 256, MaxItemTableSize: 0x10000}
+		p, err := pkg.ReadWithOptions(bytes.NewReader(data), opts)
+		if err == nil {
+			items, _ := p.Items()
+			for _, item := range items {
+				io.Copy(io.Discard, item)
+			}
+		}
+
+		pkg.StreamWithOptions(bytes.NewReader(data), opts, func(_ *pkg.Pkg, item pkg.Item, r io.Reader) error {
+			_, err := io.Copy(io.Discard, r)
+			return err
+		})
+		pkg.Salvage(bytes.NewReader(data), int64(len(data)))
+	})
+}
@@ -0,0 +1,176 @@
+package pkg
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	httpBlockSize  = 64 * 1024
+	httpCacheSize  = 64 // blocks
+	httpMaxRetries = 3
+)
+
+// HTTPReaderAt reads a remote file with range requests, in aligned blocks that are cached
+type HTTPReaderAt struct {
+	ctx    context.Context
+	client *http.Client
+	url    string
+	size   int64
+
+	mu    sync.Mutex
+	cache map[int64]*list.Element
+	lru   list.List
+}
+
+type httpBlock struct {
+	index int64
+	data  []byte
+}
+
+func NewHTTPReaderAt(ctx context.Context, client *http.Client, url string) (*HTTPReaderAt, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	r := &HTTPReaderAt{
+		ctx:    ctx,
+		client: client,
+		url:    url,
+		size:   -1,
+		cache:  make(map[int64]*list.Element),
+	}
+	// the first block is needed anyway and tells the size
+	if _, err := r.block(0); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *HTTPReaderAt) Size() int64 {
+	return r.size
+}
+
+func (r *HTTPReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos >= r.size {
+			return n, io.EOF
+		}
+		data, err := r.block(pos / httpBlockSize)
+		if err != nil {
+			return n, err
+		}
+		n += copy(p[n:], data[pos%httpBlockSize:])
+	}
+	return n, nil
+}
+
+func (r *HTTPReaderAt) block(index int64) ([]byte, error) {
+	r.mu.Lock()
+	if e, ok := r.cache[index]; ok {
+		r.lru.MoveToFront(e)
+		r.mu.Unlock()
+		return e.Value.(*httpBlock).data, nil
+	}
+	r.mu.Unlock()
+
+	data, err := r.fetchRetry(index)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.cache[index]; !ok {
+		r.cache[index] = r.lru.PushFront(&httpBlock{index: index, data: data})
+		if r.lru.Len() > httpCacheSize {
+			oldest := r.lru.Remove(r.lru.Back()).(*httpBlock)
+			delete(r.cache, oldest.index)
+		}
+	}
+	return data, nil
+}
+
+func (r *HTTPReaderAt) fetchRetry(index int64) (data []byte, err error) {
+	for attempt := 0; attempt <= httpMaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(time.Duration(100<<attempt) * time.Millisecond):
+			case <-r.ctx.Done():
+				return nil, r.ctx.Err()
+			}
+		}
+		var retry bool
+		data, retry, err = r.fetch(index)
+		if err == nil || !retry {
+			return data, err
+		}
+	}
+	return nil, err
+}
+
+// fetch requests one block, reporting if a failure is worth retrying
+func (r *HTTPReaderAt) fetch(index int64) ([]byte, bool, error) {
+	start := index * httpBlockSize
+	end := start + httpBlockSize - 1
+	if r.size >= 0 {
+		end = min(end, r.size-1)
+	}
+
+	req, err := http.NewRequestWithContext(r.ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, r.ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return nil, true, fmt.Errorf("%s: %s", r.url, resp.Status)
+	case resp.StatusCode == http.StatusOK:
+		return nil, false, fmt.Errorf("%s: server does not support range requests", r.url)
+	default:
+		return nil, false, fmt.Errorf("%s: %s", r.url, resp.Status)
+	}
+
+	if r.size < 0 {
+		size, err := parseContentRangeSize(resp.Header.Get("Content-Range"))
+		if err != nil {
+			return nil, false, err
+		}
+		r.size = size
+		end = min(end, size-1)
+	}
+
+	data := make([]byte, end-start+1)
+	if _, err := io.ReadFull(resp.Body, data); err != nil {
+		return nil, true, err
+	}
+	return data, false, nil
+}
+
+// parseContentRangeSize returns the complete length from "bytes 0-99/1234"
+func parseContentRangeSize(contentRange string) (int64, error) {
+	_, size, ok := strings.Cut(contentRange, "/")
+	if !ok || size == "*" {
+		return 0, fmt.Errorf("no size in Content-Range %q", contentRange)
+	}
+	return strconv.ParseInt(size, 10, 64)
+}
@@ -0,0 +1,96 @@
+package pkg_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/olebeck/go-pkg"
+)
+
+func TestHTTPReaderAt(t *testing.T) {
+	big := bytes.Repeat([]byte("game data "), 500000)
+	data := pkg.BuildTestPkg("EP0000-PCSE00000_00-0000000000000000", 0x15, pkg.BuildTestSfo(map[string]string{"TITLE": "remote"}), []pkg.TestItem{
+		{Name: "eboot.bin", Data: big},
+		{Name: "sce_sys/icon0.png", Data: []byte("png")},
+	})
+
+	var requests, served, failures atomic.Int64
+	failures.Store(1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if failures.Add(-1) >= 0 {
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		cw := &countingWriter{ResponseWriter: w, n: &served}
+		http.ServeContent(cw, r, "test.pkg", time.Time{}, bytes.NewReader(data))
+	}))
+	defer srv.Close()
+
+	r, err := pkg.NewHTTPReaderAt(context.Background(), srv.Client(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Size() != int64(len(data)) {
+		t.Fatalf("size %d, want %d", r.Size(), len(data))
+	}
+
+	p, err := pkg.Read(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := p.Items()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Sfo.String("TITLE") != "remote" || len(items) != 2 {
+		t.Fatalf("title %q, %d items", p.Sfo.String("TITLE"), len(items))
+	}
+	if served.Load() >= int64(len(data))/4 {
+		t.Errorf("fetched %d of %d bytes to read the header", served.Load(), len(data))
+	}
+
+	// the item behind the big one, read twice to hit the cache
+	for i := 0; i < 2; i++ {
+		buf := make([]byte, 3)
+		items[1].Seek(0, io.SeekStart)
+		if _, err := io.ReadFull(items[1], buf); err != nil || string(buf) != "png" {
+			t.Fatalf("icon0.png: %q %v", buf, err)
+		}
+	}
+	before := requests.Load()
+	items[1].Seek(0, io.SeekStart)
+	io.ReadFull(items[1], make([]byte, 3))
+	if requests.Load() != before {
+		t.Error("cached block fetched again")
+	}
+}
+
+func TestHTTPReaderAtNoRange(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("no ranges here"))
+	}))
+	defer srv.Close()
+
+	_, err := pkg.NewHTTPReaderAt(context.Background(), srv.Client(), srv.URL)
+	if err == nil || !strings.Contains(err.Error(), "range") {
+		t.Errorf("got %v", err)
+	}
+}
+
+type countingWriter struct {
+	http.ResponseWriter
+	n *atomic.Int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n.Add(int64(len(p)))
+	return w.ResponseWriter.Write(p)
+}
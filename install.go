@@ -0,0 +1,183 @@
+package pkg
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+type InstallOptions struct {
+	// raw license (rif) written next to the content
+	License []byte
+	// zRIF string decoded into License with ZRIFDict, see DecodeZRIF
+	ZRIF     string
+	ZRIFDict []byte
+}
+
+// license returns the raw license, decoding ZRIF if set
+func (opts InstallOptions) license() ([]byte, error) {
+	if opts.ZRIF == "" {
+		return opts.License, nil
+	}
+	if opts.License != nil {
+		return nil, errors.New("both License and ZRIF set")
+	}
+	license, err := DecodeZRIF(opts.ZRIF, opts.ZRIFDict)
+	if err != nil {
+		return nil, fmt.Errorf("zrif: %w", err)
+	}
+	return license, nil
+}
+
+// InstallDir returns the directory the console expects the content in, relative to ux0:
+func (p *Pkg) InstallDir() (string, error) {
+	titleID := p.TitleID()
+	if titleID == "" {
+		return "", fmt.Errorf("invalid content id %q", p.ContentID)
+	}
+
+	switch p.PkgType {
+	case PKG_TYPE_VITA_APP:
+		return "app/" + titleID, nil
+	case PKG_TYPE_VITA_PATCH:
+		return "patch/" + titleID, nil
+	case PKG_TYPE_VITA_DLC:
+		return "addcont/" + titleID + "/" + p.Label(), nil
+	case PKG_TYPE_VITA_THEME:
+		return "theme/" + titleID + "-" + p.Label(), nil
+	case PKG_TYPE_VITA_PSM:
+		return "psm/" + titleID, nil
+	case PKG_TYPE_PSP, PKG_TYPE_PSX:
+		return "pspemu/PSP/GAME/" + titleID, nil
+	default:
+		return "", fmt.Errorf("package type %d has no install location", p.PkgType)
+	}
+}
+
+// LicensePath returns where the license for this package goes, relative to ux0:
+func (p *Pkg) LicensePath() (string, error) {
+	dir, err := p.InstallDir()
+	if err != nil {
+		return "", err
+	}
+
+	switch p.PkgType {
+	case PKG_TYPE_VITA_APP, PKG_TYPE_VITA_PATCH:
+		return "license/app/" + p.TitleID() + "/" + p.ContentID + ".rif", nil
+	case PKG_TYPE_VITA_DLC:
+		return "license/addcont/" + p.TitleID() + "/" + p.Label() + "/" + p.ContentID + ".rif", nil
+	case PKG_TYPE_VITA_THEME:
+		return "license/theme/" + p.TitleID() + "-" + p.Label() + "/" + p.ContentID + ".rif", nil
+	case PKG_TYPE_VITA_PSM:
+		return dir + "/RO/License/FAKE.rif", nil
+	default:
+		return "", fmt.Errorf("package type %d has no license file", p.PkgType)
+	}
+}
+
+// installName maps an item name to its path inside InstallDir
+func (p *Pkg) installName(name string) string {
+	switch p.PkgType {
+	case PKG_TYPE_VITA_PSM:
+		if name == "contents" {
+			return "RO"
+		}
+		if rest, ok := strings.CutPrefix(name, "contents/"); ok {
+			return "RO/" + rest
+		}
+	case PKG_TYPE_PSP, PKG_TYPE_PSX:
+		if rest, ok := strings.CutPrefix(name, "USRDIR/CONTENT/"); ok {
+			return rest
+		}
+	}
+	return name
+}
+
+type installFile struct {
+	name string // relative to ux0:
+	Item
+}
+
+// installFiles returns the package content, sce_sys/package and license as laid out on the console,
+// in the order they are stored in the package
+func (p *Pkg) installFiles(license []byte) ([]installFile, error) {
+	dir, err := p.InstallDir()
+	if err != nil {
+		return nil, err
+	}
+	// the content id comes from the header, make sure it can't move the install dir
+	if clean, err := SafeName(dir); err != nil || clean != dir {
+		return nil, fmt.Errorf("invalid install dir %q: %w", dir, ErrUnsafePath)
+	}
+
+	items, err := p.Items()
+	if err != nil {
+		return nil, err
+	}
+	items = slices.Clone(items)
+	slices.SortStableFunc(items, func(a, b Item) int {
+		return cmp.Compare(a.offset, b.offset)
+	})
+	if p.hasPackageFiles() {
+		pf := p.PackageFiles(license)
+		// head.bin comes before any item data, the rest after it
+		items = append(append(pf[:1:1], items...), pf[1:]...)
+	}
+
+	var files []installFile
+	seen := make(map[string]bool)
+	for _, item := range items {
+		name, err := SafeName(p.installName(item.Name))
+		if err != nil {
+			return nil, &ItemError{Name: item.Name, Err: err}
+		}
+		name = dir + "/" + name
+		if seen[name] {
+			return nil, &ItemError{Name: item.Name, Err: ErrDuplicateItem}
+		}
+		seen[name] = true
+		files = append(files, installFile{name: name, Item: item})
+	}
+
+	if license != nil {
+		name, err := p.LicensePath()
+		if err != nil {
+			return nil, err
+		}
+		if clean, err := SafeName(name); err != nil || clean != name {
+			return nil, fmt.Errorf("invalid license path %q: %w", name, ErrUnsafePath)
+		}
+		files = append(files, installFile{
+			name: name,
+			Item: Item{Name: path.Base(name), Size: len(license), ReadSeeker: bytes.NewReader(license)},
+		})
+	}
+	return files, nil
+}
+
+// Install writes the package content, sce_sys/package and license below root in the layout the console expects
+func Install(ctx context.Context, p *Pkg, root string, opts InstallOptions) error {
+	license, err := opts.license()
+	if err != nil {
+		return err
+	}
+	files, err := p.installFiles(license)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := writeItem(root, file.name, file.Item); err != nil {
+			return &ItemError{Name: file.Name, Err: err}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package pkg_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/olebeck/go-pkg"
+)
+
+func TestInstall(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		contentID   string
+		contentType uint32
+		sfo         map[string]string
+		items       []pkg.TestItem
+		files       []string // first one holds the last item
+	}{
+		{
+			name:        "app",
+			contentID:   "EP0000-PCSE00000_00-0000000000000000",
+			contentType: 0x15,
+			sfo:         map[string]string{"CATEGORY": "gd", "TITLE": "test"},
+			items:       []pkg.TestItem{{Name: "sce_sys", Flags: 4}, {Name: "sce_sys/icon0.png", Data: []byte("png")}, {Name: "eboot.bin", Data: []byte("elf")}},
+			files:       []string{"app/PCSE00000/eboot.bin", "app/PCSE00000/sce_sys/icon0.png", "license/app/PCSE00000/EP0000-PCSE00000_00-0000000000000000.rif"},
+		},
+		{
+			name:        "patch",
+			contentID:   "EP0000-PCSE00000_00-0000000000000000",
+			contentType: 0x15,
+			sfo:         map[string]string{"CATEGORY": "gp"},
+			items:       []pkg.TestItem{{Name: "eboot.bin", Data: []byte("elf")}},
+			files:       []string{"patch/PCSE00000/eboot.bin"},
+		},
+		{
+			name:        "dlc",
+			contentID:   "EP0000-PCSE00000_00-DLC0000000000001",
+			contentType: 0x16,
+			items:       []pkg.TestItem{{Name: "data.bin", Data: []byte("data")}},
+			files:       []string{"addcont/PCSE00000/DLC0000000000001/data.bin", "license/addcont/PCSE00000/DLC0000000000001/EP0000-PCSE00000_00-DLC0000000000001.rif"},
+		},
+		{
+			name:        "psm",
+			contentID:   "EP0000-NPOA00000_00-0000000000000000",
+			contentType: 0x18,
+			items:       []pkg.TestItem{{Name: "contents/Application/app.exe", Data: []byte("exe")}},
+			files:       []string{"psm/NPOA00000/RO/Application/app.exe", "psm/NPOA00000/RO/License/FAKE.rif"},
+		},
+		{
+			name:        "theme",
+			contentID:   "EP0000-PCSI00000_00-THEME00000000001",
+			contentType: 0x1f,
+			items:       []pkg.TestItem{{Name: "theme.xml", Data: []byte("<theme/>")}},
+			files:       []string{"theme/PCSI00000-THEME00000000001/theme.xml", "theme/PCSI00000-THEME00000000001/sce_sys/package/head.bin", "license/theme/PCSI00000-THEME00000000001/EP0000-PCSI00000_00-THEME00000000001.rif"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var paramSfo []byte
+			if tc.sfo != nil {
+				paramSfo = pkg.BuildTestSfo(tc.sfo)
+			}
+			data := pkg.BuildTestPkg(tc.contentID, tc.contentType, paramSfo, tc.items)
+			p, err := pkg.Read(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			root := t.TempDir()
+			if err := pkg.Install(context.Background(), p, root, pkg.InstallOptions{License: []byte("rif")}); err != nil {
+				t.Fatal(err)
+			}
+			for _, f := range tc.files {
+				if _, err := os.Stat(filepath.Join(root, f)); err != nil {
+					t.Error(err)
+				}
+			}
+			got, _ := os.ReadFile(filepath.Join(root, tc.files[0]))
+			if want := tc.items[len(tc.items)-1].Data; !bytes.Equal(got, want) {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestInstallPackageFiles(t *testing.T) {
+	data := pkg.BuildTestPkg("EP0000-PCSE00000_00-0000000000000000", 0x15, nil, []pkg.TestItem{{Name: "eboot.bin", Data: []byte("elf")}})
+	p, err := pkg.Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := t.TempDir()
+	if err := pkg.Install(context.Background(), p, root, pkg.InstallOptions{License: []byte("rif")}); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(root, "app/PCSE00000/sce_sys/package")
+	head, _ := os.ReadFile(filepath.Join(dir, "head.bin"))
+	if !bytes.HasPrefix(data, head) || !bytes.HasPrefix(head, []byte("\x7fPKG")) {
+		t.Errorf("head.bin is not a prefix of the package")
+	}
+	if tail, _ := os.ReadFile(filepath.Join(dir, "tail.bin")); !bytes.Equal(tail, bytes.Repeat([]byte{0xAA}, 0x20)) {
+		t.Errorf("tail.bin: %x", tail)
+	}
+	if stat, _ := os.ReadFile(filepath.Join(dir, "stat.bin")); len(stat) != 768 {
+		t.Errorf("stat.bin is %d bytes", len(stat))
+	}
+	if work, _ := os.ReadFile(filepath.Join(dir, "work.bin")); string(work) != "rif" {
+		t.Errorf("work.bin: %q", work)
+	}
+}
+
+func TestInstallZRIF(t *testing.T) {
+	// a stand-in dictionary, real zRIFs need zrif_dict from pkg2zip
+	dict := bytes.Repeat([]byte("EP0000-PCSE00000_00-0000000000000000"), 8)
+	license := append([]byte("rif\x00"), dict[:36]...)
+	var buf bytes.Buffer
+	zw, err := zlib.NewWriterLevelDict(&buf, zlib.BestCompression, dict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw.Write(license)
+	zw.Close()
+	zrif := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	data := pkg.BuildTestPkg("EP0000-PCSE00000_00-0000000000000000", 0x15, pkg.BuildTestSfo(map[string]string{"CATEGORY": "gd"}), []pkg.TestItem{
+		{Name: "eboot.bin", Data: []byte("elf")},
+	})
+	p, err := pkg.Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := t.TempDir()
+	if err := pkg.Install(context.Background(), p, root, pkg.InstallOptions{ZRIF: zrif, ZRIFDict: dict}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join(root, "license/app/PCSE00000/EP0000-PCSE00000_00-0000000000000000.rif"))
+	if err != nil || !bytes.Equal(got, license) {
+		t.Errorf("license %q, %v", got, err)
+	}
+
+	if err := pkg.Install(context.Background(), p, t.TempDir(), pkg.InstallOptions{ZRIF: zrif}); err == nil {
+		t.Error("zrif decoded without its dictionary")
+	}
+	if err := pkg.Install(context.Background(), p, t.TempDir(), pkg.InstallOptions{ZRIF: zrif, ZRIFDict: dict, License: license}); err == nil {
+		t.Error("License and ZRIF both accepted")
+	}
+}
@@ -0,0 +1,27 @@
+// Package pbptest builds EBOOT.PBP files for the tests of this module
+package pbptest
+
+import "encoding/binary"
+
+const numSections = 8
+
+// Build builds a version 1.0 EBOOT.PBP from sections in pbp order (PARAM.SFO, ICON0.PNG, ..., DATA.PSAR), missing sections are left empty
+func Build(sections ...[]byte) []byte {
+	if len(sections) > numSections {
+		panic("pbptest: too many sections")
+	}
+	out := make([]byte, 0x28)
+	copy(out, "\x00PBP")
+	binary.LittleEndian.PutUint32(out[4:], 0x10000)
+	off := uint32(len(out))
+	for i := 0; i < numSections; i++ {
+		binary.LittleEndian.PutUint32(out[8+i*4:], off)
+		if i < len(sections) {
+			off += uint32(len(sections[i]))
+		}
+	}
+	for _, data := range sections {
+		out = append(out, data...)
+	}
+	return out
+}
@@ -0,0 +1,110 @@
+package livearea
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// Template is sce_sys/livearea/contents/template.xml
+type Template struct {
+	XMLName    xml.Name `xml:"livearea"`
+	Style      string   `xml:"style,attr"`
+	FormatVer  string   `xml:"format-ver,attr"`
+	ContentRev string   `xml:"content-rev,attr"`
+
+	Background []Image `xml:"livearea-background>image"`
+	Gate       Gate    `xml:"gate"`
+	Frames     []Frame `xml:"frame"`
+}
+
+type Gate struct {
+	StartupImage []Image `xml:"startup-image"`
+}
+
+type Frame struct {
+	ID        string     `xml:"id,attr"`
+	MultiMode string     `xml:"multi,attr"`
+	Items     []LiveItem `xml:"liveitem"`
+}
+
+// LiveItem is one variant of a frame, picked by country, language and date
+type LiveItem struct {
+	Country string `xml:"cntry,attr"`
+	Lang    string `xml:"lang,attr"`
+	From    string `xml:"from,attr"`
+	Until   string `xml:"until,attr"`
+
+	Background []Image `xml:"background"`
+	Images     []Image `xml:"image"`
+	Texts      []Text  `xml:"text"`
+	Target     string  `xml:"target"`
+}
+
+// Image is a file name relative to the livearea contents directory
+type Image struct {
+	Lang    string `xml:"lang,attr"`
+	Country string `xml:"cntry,attr"`
+	Name    string `xml:",chardata"`
+}
+
+type Text struct {
+	Align        string `xml:"align,attr"`
+	VAlign       string `xml:"valign,attr"`
+	Width        string `xml:"width,attr"`
+	Height       string `xml:"height,attr"`
+	MarginLeft   string `xml:"margin-left,attr"`
+	MarginRight  string `xml:"margin-right,attr"`
+	MarginTop    string `xml:"margin-top,attr"`
+	MarginBottom string `xml:"margin-bottom,attr"`
+	WordWrap     string `xml:"word-wrap,attr"`
+	WordScroll   string `xml:"word-scroll,attr"`
+	Ellipsis     string `xml:"ellipsis,attr"`
+	LineSpace    string `xml:"line-space,attr"`
+	Lang         string `xml:"lang,attr"`
+	Strings      []Str  `xml:"str"`
+}
+
+type Str struct {
+	Size      string `xml:"size,attr"`
+	Color     string `xml:"color,attr"`
+	Bold      string `xml:"bold,attr"`
+	Italic    string `xml:"italic,attr"`
+	Underline string `xml:"underline,attr"`
+	Shadow    string `xml:"shadow,attr"`
+	Value     string `xml:",chardata"`
+}
+
+func Parse(r io.Reader) (*Template, error) {
+	var t Template
+	if err := xml.NewDecoder(r).Decode(&t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ImageNames returns the image files the template references, in document order without duplicates
+func (t *Template) ImageNames() []string {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(images []Image) {
+		for _, img := range images {
+			name := strings.TrimSpace(img.Name)
+			if name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	add(t.Background)
+	add(t.Gate.StartupImage)
+	for _, frame := range t.Frames {
+		for _, item := range frame.Items {
+			add(item.Background)
+			add(item.Images)
+		}
+	}
+	return names
+}
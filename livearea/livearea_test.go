@@ -0,0 +1,63 @@
+package livearea_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/olebeck/go-pkg/livearea"
+)
+
+const testTemplate = `<?xml version="1.0" encoding="utf-8"?>
+<livearea style="a1" format-ver="01.00" content-rev="1">
+  <livearea-background>
+    <image>bg.png</image>
+  </livearea-background>
+  <gate>
+    <startup-image>startup.png</startup-image>
+  </gate>
+  <frame id="frame1">
+    <liveitem>
+      <image>frame1.png</image>
+    </liveitem>
+    <liveitem lang="ja">
+      <image>frame1_ja.png</image>
+    </liveitem>
+  </frame>
+  <frame id="frame2">
+    <liveitem>
+      <image> bg.png </image>
+      <text align="left" valign="top" word-wrap="on">
+        <str size="22" color="#ffffff" bold="on">Hello</str>
+      </text>
+    </liveitem>
+  </frame>
+</livearea>`
+
+func TestParse(t *testing.T) {
+	tmpl, err := livearea.Parse(strings.NewReader(testTemplate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmpl.Style != "a1" || len(tmpl.Frames) != 2 || tmpl.Frames[0].ID != "frame1" {
+		t.Fatalf("unexpected template %+v", tmpl)
+	}
+	if item := tmpl.Frames[0].Items[1]; item.Lang != "ja" {
+		t.Errorf("liveitem lang = %q", item.Lang)
+	}
+	text := tmpl.Frames[1].Items[0].Texts[0]
+	if text.Align != "left" || len(text.Strings) != 1 || text.Strings[0].Value != "Hello" || text.Strings[0].Color != "#ffffff" {
+		t.Errorf("unexpected text %+v", text)
+	}
+
+	want := []string{"bg.png", "startup.png", "frame1.png", "frame1_ja.png"}
+	if got := tmpl.ImageNames(); !slices.Equal(got, want) {
+		t.Errorf("ImageNames() = %q, want %q", got, want)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	if _, err := livearea.Parse(strings.NewReader("<gate></gate>")); err == nil {
+		t.Error("expected error for wrong root element")
+	}
+}
@@ -0,0 +1,62 @@
+//go:build linux
+
+package pkg
+
+import (
+	"io"
+	"os"
+	"sync"
+	"syscall"
+)
+
+type mmapReader struct {
+	mu   sync.RWMutex
+	data []byte
+}
+
+// openReaderAt maps f and closes it, the mapping stays valid on its own
+func openReaderAt(f *os.File, size int64) (readerAtCloser, error) {
+	if size <= 0 || int64(int(size)) != size {
+		return f, nil
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		// not every filesystem can be mapped
+		return f, nil
+	}
+	f.Close()
+	return &mmapReader{data: data}, nil
+}
+
+func (m *mmapReader) ReadAt(p []byte, off int64) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.data == nil {
+		return 0, os.ErrClosed
+	}
+	if off < 0 || off >= int64(len(m.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, m.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (m *mmapReader) Size() int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return int64(len(m.data))
+}
+
+func (m *mmapReader) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.data == nil {
+		return os.ErrClosed
+	}
+	err := syscall.Munmap(m.data)
+	m.data = nil
+	return err
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package pkg
+
+import "os"
+
+func openReaderAt(f *os.File, size int64) (readerAtCloser, error) {
+	return f, nil
+}
@@ -0,0 +1,171 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+)
+
+type SizeReaderAt interface {
+	io.ReaderAt
+	Size() int64
+}
+
+// MultiReaderAt is the concatenation of its parts
+type MultiReaderAt struct {
+	parts  []SizeReaderAt
+	starts []int64
+	size   int64
+}
+
+func NewMultiReaderAt(parts ...SizeReaderAt) *MultiReaderAt {
+	m := &MultiReaderAt{parts: parts}
+	for _, part := range parts {
+		m.starts = append(m.starts, m.size)
+		m.size += part.Size()
+	}
+	return m
+}
+
+func (m *MultiReaderAt) Size() int64 {
+	return m.size
+}
+
+func (m *MultiReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	// last part starting at or before off
+	i := sort.Search(len(m.starts), func(i int) bool { return m.starts[i] > off }) - 1
+	for ; i >= 0 && i < len(m.parts) && n < len(p); i++ {
+		pos := off + int64(n) - m.starts[i]
+		size := m.parts[i].Size()
+		if pos >= size {
+			continue
+		}
+		want := p[n:min(len(p), n+int(size-pos))]
+		read, err := m.parts[i].ReadAt(want, pos)
+		n += read
+		if err != nil && !(err == io.EOF && read == len(want)) {
+			return n, err
+		}
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+var partSuffix = regexp.MustCompile(`(?i)^(.*)_(\d+)\.pkg$`)
+
+// Parts is a package split into _0.pkg, _1.pkg, ... files
+type Parts struct {
+	*MultiReaderAt
+	Paths []string
+	files []*os.File
+}
+
+// OpenParts opens the parts of a split package in suffix order.
+// A single path ending in _N.pkg opens all parts next to it.
+func OpenParts(paths ...string) (*Parts, error) {
+	if len(paths) == 1 {
+		if m := partSuffix.FindStringSubmatch(paths[0]); m != nil {
+			siblings, err := partSiblings(m[1])
+			if err != nil {
+				return nil, err
+			}
+			paths = siblings
+		}
+	}
+
+	type part struct {
+		path  string
+		index int
+	}
+	var parts []part
+	var prefix string
+	for _, p := range paths {
+		m := partSuffix.FindStringSubmatch(p)
+		if m == nil {
+			if len(paths) == 1 {
+				parts = append(parts, part{path: p})
+				break
+			}
+			return nil, fmt.Errorf("%s: no _N.pkg part suffix", p)
+		}
+		index, err := strconv.Atoi(m[2])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		if len(parts) == 0 {
+			prefix = filepath.Clean(m[1])
+		} else if filepath.Clean(m[1]) != prefix {
+			return nil, fmt.Errorf("%s is not a part of %s", p, prefix)
+		}
+		parts = append(parts, part{path: p, index: index})
+	}
+	if len(parts) == 0 {
+		return nil, errors.New("no package parts")
+	}
+
+	slices.SortFunc(parts, func(a, b part) int { return a.index - b.index })
+	for i, part := range parts {
+		if part.index != i {
+			return nil, fmt.Errorf("missing part %d of %s", i, prefix)
+		}
+	}
+
+	ps := &Parts{}
+	var readers []SizeReaderAt
+	for _, part := range parts {
+		f, err := os.Open(part.path)
+		if err != nil {
+			ps.Close()
+			return nil, err
+		}
+		ps.files = append(ps.files, f)
+		ps.Paths = append(ps.Paths, part.path)
+
+		st, err := f.Stat()
+		if err != nil {
+			ps.Close()
+			return nil, err
+		}
+		readers = append(readers, io.NewSectionReader(f, 0, st.Size()))
+	}
+	ps.MultiReaderAt = NewMultiReaderAt(readers...)
+	return ps, nil
+}
+
+func (p *Parts) Close() error {
+	var errs []error
+	for _, f := range p.files {
+		errs = append(errs, f.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// partSiblings lists the files named prefix_N.pkg
+func partSiblings(prefix string) ([]string, error) {
+	prefix = filepath.Clean(prefix)
+	dir := filepath.Dir(prefix)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, e := range entries {
+		name := filepath.Join(dir, e.Name())
+		if m := partSuffix.FindStringSubmatch(name); m != nil && filepath.Clean(m[1]) == prefix {
+			paths = append(paths, name)
+		}
+	}
+	return paths, nil
+}
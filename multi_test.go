@@ -0,0 +1,81 @@
+package pkg_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/olebeck/go-pkg"
+)
+
+func TestMultiReaderAt(t *testing.T) {
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	m := pkg.NewMultiReaderAt(bytes.NewReader(data[:100]), bytes.NewReader(nil), bytes.NewReader(data[100:550]), bytes.NewReader(data[550:]))
+
+	for _, tc := range []struct{ off, n int }{{0, 1000}, {90, 20}, {99, 1}, {100, 450}, {549, 2}, {999, 1}} {
+		buf := make([]byte, tc.n)
+		if _, err := m.ReadAt(buf, int64(tc.off)); err != nil {
+			t.Fatalf("%d+%d: %v", tc.off, tc.n, err)
+		}
+		if !bytes.Equal(buf, data[tc.off:tc.off+tc.n]) {
+			t.Errorf("%d+%d: content mismatch", tc.off, tc.n)
+		}
+	}
+	n, err := m.ReadAt(make([]byte, 10), 995)
+	if n != 5 || err != io.EOF {
+		t.Errorf("read past end: %d %v", n, err)
+	}
+}
+
+func TestOpenParts(t *testing.T) {
+	data := pkg.BuildTestPkg("UP0000-NPUB00000_00-0000000000000000", 0x16, nil, []pkg.TestItem{
+		{Name: "data.bin", Data: bytes.Repeat([]byte("split"), 1000)},
+	})
+
+	dir := t.TempDir()
+	split := []int{0, 300, 2000, len(data)}
+	for i := 0; i < 3; i++ {
+		name := filepath.Join(dir, "game_"+string(rune('0'+i))+".pkg")
+		if err := os.WriteFile(name, data[split[i]:split[i+1]], 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	parts, err := pkg.OpenParts(filepath.Join(dir, "game_0.pkg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parts.Close()
+	if len(parts.Paths) != 3 {
+		t.Fatalf("found parts %v", parts.Paths)
+	}
+
+	p, err := pkg.Read(parts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, _ := p.Items()
+	got, _ := io.ReadAll(items[0])
+	if !bytes.Equal(got, bytes.Repeat([]byte("split"), 1000)) {
+		t.Error("content mismatch")
+	}
+
+	if _, err := pkg.OpenParts(filepath.Join(dir, "game_2.pkg"), filepath.Join(dir, "game_0.pkg")); err == nil {
+		t.Error("missing part 1 not detected")
+	}
+
+	// an unclean path, its siblings are listed as clean paths
+	rel, err := pkg.OpenParts(dir + string(filepath.Separator) + "." + string(filepath.Separator) + "game_0.pkg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rel.Close()
+	if len(rel.Paths) != 3 {
+		t.Errorf("found parts %v from an unclean path", rel.Paths)
+	}
+}
@@ -0,0 +1,36 @@
+package pkg
+
+import (
+	"bytes"
+	"io"
+)
+
+const statSize = 768
+
+// PackageFiles returns the files pkg2zip and the console keep in sce_sys/package:
+// head.bin (header, metadata and item table as stored), tail.bin (everything after the encrypted data),
+// an empty stat.bin and work.bin holding the license if one is given
+func (p *Pkg) PackageFiles(license []byte) []Item {
+	headSize := p.encryptedOffset + p.itemOffset + p.itemSize
+	tailOffset := p.encryptedOffset + p.encryptedSize
+	tailSize := max(p.totalSize-tailOffset, 0)
+
+	items := []Item{
+		{Name: "sce_sys/package/head.bin", Size: int(headSize), ReadSeeker: io.NewSectionReader(p.r, 0, headSize)},
+		{Name: "sce_sys/package/tail.bin", Size: int(tailSize), ReadSeeker: io.NewSectionReader(p.r, tailOffset, tailSize)},
+		{Name: "sce_sys/package/stat.bin", Size: statSize, ReadSeeker: bytes.NewReader(make([]byte, statSize))},
+	}
+	if license != nil {
+		items = append(items, Item{Name: "sce_sys/package/work.bin", Size: len(license), ReadSeeker: bytes.NewReader(license)})
+	}
+	return items
+}
+
+// hasPackageFiles reports if the console expects sce_sys/package for this package type
+func (p *Pkg) hasPackageFiles() bool {
+	switch p.PkgType {
+	case PKG_TYPE_VITA_APP, PKG_TYPE_VITA_PATCH, PKG_TYPE_VITA_DLC, PKG_TYPE_VITA_THEME:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package pbp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/olebeck/go-pkg/sfo"
+)
+
+const pbpMagic = "\x00PBP"
+
+const headerSize = 0x28
+
+type Section int
+
+const (
+	ParamSfo Section = iota
+	Icon0
+	Icon1
+	Pic0
+	Pic1
+	Snd0
+	DataPsp
+	DataPsar
+	numSections
+)
+
+var sectionNames = [numSections]string{"PARAM.SFO", "ICON0.PNG", "ICON1.PMF", "PIC0.PNG", "PIC1.PNG", "SND0.AT3", "DATA.PSP", "DATA.PSAR"}
+
+// String returns the file name of the section
+func (s Section) String() string {
+	if s < 0 || s >= numSections {
+		return fmt.Sprintf("Section(%d)", int(s))
+	}
+	return sectionNames[s]
+}
+
+// File is an EBOOT.PBP, sections are read from the underlying reader on demand
+type File struct {
+	Version uint32
+	Offsets [numSections]uint32
+
+	r    io.ReaderAt
+	size int64
+}
+
+func Read(r io.ReaderAt, size int64) (*File, error) {
+	var header [headerSize]byte
+	if _, err := r.ReadAt(header[:], 0); err != nil {
+		return nil, err
+	}
+	if string(header[:4]) != pbpMagic {
+		return nil, errors.New("not a pbp file")
+	}
+
+	f := &File{
+		Version: binary.LittleEndian.Uint32(header[4:]),
+		r:       r,
+		size:    size,
+	}
+	prev := int64(headerSize)
+	for i := range f.Offsets {
+		f.Offsets[i] = binary.LittleEndian.Uint32(header[8+i*4:])
+		if off := int64(f.Offsets[i]); off < prev || off > size {
+			return nil, fmt.Errorf("%s offset 0x%x out of range", Section(i), off)
+		}
+		prev = int64(f.Offsets[i])
+	}
+	return f, nil
+}
+
+// Section returns a reader over a section, absent sections have size 0
+func (f *File) Section(s Section) *io.SectionReader {
+	if s < 0 || s >= numSections {
+		return io.NewSectionReader(f.r, 0, 0)
+	}
+	end := f.size
+	if s+1 < numSections {
+		end = int64(f.Offsets[s+1])
+	}
+	start := int64(f.Offsets[s])
+	return io.NewSectionReader(f.r, start, end-start)
+}
+
+// Sfo decodes the embedded PARAM.SFO
+func (f *File) Sfo() (*sfo.File, error) {
+	sr := f.Section(ParamSfo)
+	if sr.Size() == 0 {
+		return nil, errors.New("pbp has no PARAM.SFO")
+	}
+	return sfo.Decode(sr)
+}
@@ -0,0 +1,70 @@
+package pbp_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/olebeck/go-pkg/internal/pbptest"
+	"github.com/olebeck/go-pkg/pbp"
+	"github.com/olebeck/go-pkg/sfo"
+)
+
+func buildPbp(sections map[pbp.Section][]byte) []byte {
+	ordered := make([][]byte, pbp.DataPsar+1)
+	for s, data := range sections {
+		ordered[s] = data
+	}
+	return pbptest.Build(ordered...)
+}
+
+func TestRead(t *testing.T) {
+	var paramSfo bytes.Buffer
+	f := &sfo.File{}
+	f.SetString("TITLE", "PSP Game")
+	if err := sfo.Encode(&paramSfo, f); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buildPbp(map[pbp.Section][]byte{
+		pbp.ParamSfo: paramSfo.Bytes(),
+		pbp.Icon0:    []byte("icon0"),
+		pbp.Pic1:     []byte("pic1"),
+		pbp.DataPsar: []byte("psar data"),
+	})
+	p, err := pbp.Read(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for s, want := range map[pbp.Section]string{pbp.Icon0: "icon0", pbp.Pic0: "", pbp.Pic1: "pic1", pbp.DataPsar: "psar data"} {
+		got, err := io.ReadAll(p.Section(s))
+		if err != nil || string(got) != want {
+			t.Errorf("%s = %q, %v, want %q", s, got, err, want)
+		}
+	}
+
+	param, err := p.Sfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if title := param.String("TITLE"); title != "PSP Game" {
+		t.Errorf("TITLE = %q", title)
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	data := buildPbp(map[pbp.Section][]byte{pbp.Icon0: []byte("icon0")})
+	bad := bytes.Clone(data)
+	binary.LittleEndian.PutUint32(bad[8+4*int(pbp.Pic0):], 0x1000)
+	if _, err := pbp.Read(bytes.NewReader(bad), int64(len(bad))); err == nil {
+		t.Error("expected error for offset out of range")
+	}
+	if _, err := pbp.Read(bytes.NewReader(data[:0x10]), 0x10); err == nil {
+		t.Error("expected error for short header")
+	}
+	if _, err := pbp.Read(bytes.NewReader([]byte("not a pbp file at all, really not one")), 38); err == nil {
+		t.Error("expected error for bad magic")
+	}
+}
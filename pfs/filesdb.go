@@ -7,8 +7,13 @@ import (
 	"crypto/hmac"
 	"crypto/sha1"
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"io"
+	"math"
+	"os"
 	"slices"
+	"sync"
 )
 
 const filesdbMagic = "SCENGPFS"
@@ -67,54 +72,236 @@ type page_icv_data struct {
 
 type FilesDB struct {
 	Header   sce_ng_pfs_header_t
-	Blocks   []sce_ng_pfs_block_t
 	PageIcvs map[uint32][]page_icv_data
+
+	HeaderIcvValid bool // Header_icv matches the header
+	RootIcvValid   bool // Root_icv matches the root page
+
+	r                io.ReaderAt
+	secret           []byte
+	allowInvalidRoot bool
+
+	mu    sync.Mutex
+	pages map[uint32]*sce_ng_pfs_block_t
+}
+
+type ParseOptions struct {
+	// load and validate every page while parsing instead of on first access,
+	// fails if the header, root page or any page in the tree is invalid
+	Eager bool
+	// largest accepted page size, 0 uses DefaultMaxPageSize
+	MaxPageSize uint32
+	// let Page load pages when the root page fails its icv check,
+	// their icvs are then only checked against an unverified root
+	AllowInvalidRoot bool
 }
 
-func ParseFilesDB(r io.Reader, klicensee, klicenseeDeriv []byte) (*FilesDB, error) {
-	var filesDB FilesDB
-	if err := binary.Read(r, binary.LittleEndian, &filesDB.Header); err != nil {
+const DefaultMaxPageSize = 0x10000
+
+var ErrInvalidRootIcv = errors.New("invalid root icv")
+
+var headerSize = int64(binary.Size(sce_ng_pfs_header_t{}))
+var blockSize = binary.Size(sce_ng_pfs_block_t{})
+
+func ParseFilesDB(r io.ReaderAt, klicensee, klicenseeDeriv []byte, opts ParseOptions) (*FilesDB, error) {
+	filesDB := &FilesDB{
+		r:                r,
+		pages:            make(map[uint32]*sce_ng_pfs_block_t),
+		allowInvalidRoot: opts.AllowInvalidRoot,
+	}
+	if err := binary.Read(io.NewSectionReader(r, 0, headerSize), binary.LittleEndian, &filesDB.Header); err != nil {
 		return nil, err
 	}
-	filesDB.PageIcvs = make(map[uint32][]page_icv_data)
+	if string(filesDB.Header.Magic[:]) != filesdbMagic {
+		return nil, fmt.Errorf("wrong magic %q", filesDB.Header.Magic[:])
+	}
+	if opts.MaxPageSize == 0 {
+		opts.MaxPageSize = DefaultMaxPageSize
+	}
+	if filesDB.Header.PageSize < uint32(blockSize) || filesDB.Header.PageSize > opts.MaxPageSize {
+		return nil, fmt.Errorf("invalid page size 0x%x", filesDB.Header.PageSize)
+	}
+	pages := filesDB.Header.TailSize / uint64(filesDB.Header.PageSize)
+	if pages > math.MaxUint32 {
+		return nil, fmt.Errorf("page count 0x%x out of range", pages)
+	}
+	if size := readerSize(r); size >= 0 && pages > uint64(max(size-headerSize, 0))/uint64(filesDB.Header.PageSize) {
+		return nil, fmt.Errorf("%d pages do not fit in 0x%x bytes", pages, size)
+	}
+
+	filesDB.secret = get_secret(klicensee, klicenseeDeriv, filesDB.Header.Files_salt, CRYPTO_ENGINE_CRYPTO_USE_KEYGEN, 0, 0)
+	filesDB.HeaderIcvValid = hmac.Equal(calculate_header_icv(&filesDB.Header, filesDB.secret), filesDB.Header.Header_icv[:])
 
-	secret := get_secret(klicensee, klicenseeDeriv, filesDB.Header.Files_salt, CRYPTO_ENGINE_CRYPTO_USE_KEYGEN, 0, 0)
+	// the root page anchors every other page check
+	root, rootIcv, err := filesDB.readPage(filesDB.Header.Root_icv_page_number)
+	if err != nil {
+		return nil, err
+	}
+	filesDB.RootIcvValid = rootIcv == filesDB.Header.Root_icv
+	filesDB.pages[filesDB.Header.Root_icv_page_number] = root
 
-	blockCount := filesDB.Header.TailSize / uint64(filesDB.Header.PageSize)
-	var raw_block = bytes.NewBuffer(make([]byte, 1024))
-	for page := 0; page < int(blockCount); page++ {
-		raw_block.Reset()
-		var block sce_ng_pfs_block_t
-		if err := binary.Read(io.TeeReader(r, raw_block), binary.LittleEndian, &block); err != nil {
+	if opts.Eager {
+		if !filesDB.HeaderIcvValid {
+			return nil, errors.New("invalid header icv")
+		}
+		if !filesDB.RootIcvValid {
+			return nil, ErrInvalidRootIcv
+		}
+		if err := filesDB.loadAll(); err != nil {
 			return nil, err
 		}
+	}
+	return filesDB, nil
+}
 
-		icv := page_icv_data{
-			Offset: 0,
-			Page:   uint32(page),
+func (fdb *FilesDB) NumPages() uint32 {
+	return uint32(fdb.Header.TailSize / uint64(fdb.Header.PageSize))
+}
+
+// Page returns a page of the tree, reading it on first access and checking its icv against the parent chain up to the root.
+// It fails with ErrInvalidRootIcv if the root page did not match the header unless ParseOptions.AllowInvalidRoot is set.
+func (fdb *FilesDB) Page(page uint32) (*sce_ng_pfs_block_t, error) {
+	if !fdb.RootIcvValid && !fdb.allowInvalidRoot {
+		return nil, ErrInvalidRootIcv
+	}
+
+	type link struct {
+		page  uint32
+		block *sce_ng_pfs_block_t
+		icv   [0x14]byte
+	}
+
+	// walk up to a loaded page, the root is loaded by ParseFilesDB
+	var chain []link
+	var parent *sce_ng_pfs_block_t
+	seen := make(map[uint32]bool)
+	for p := page; ; {
+		fdb.mu.Lock()
+		block, ok := fdb.pages[p]
+		fdb.mu.Unlock()
+		if ok {
+			parent = block
+			break
+		}
+		if seen[p] {
+			return nil, fmt.Errorf("page %d: parent loop", page)
+		}
+		seen[p] = true
+
+		block, icv, err := fdb.readPage(p)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, link{p, block, icv})
+		if p == fdb.Header.Root_icv_page_number {
+			break
 		}
+		p = block.Header.Parent_page_number
+	}
+	if len(chain) == 0 {
+		return parent, nil
+	}
+
+	// then check every icv going back down
+	for i := len(chain) - 1; i >= 0; i-- {
+		l := chain[i]
+		if l.page != fdb.Header.Root_icv_page_number && !slices.Contains(parent.FileHashes[:], l.icv) {
+			return nil, fmt.Errorf("page %d: icv mismatch", l.page)
+		}
+		fdb.mu.Lock()
+		fdb.pages[l.page] = l.block
+		fdb.mu.Unlock()
+		parent = l.block
+	}
+	return parent, nil
+}
+
+func (fdb *FilesDB) readPage(page uint32) (*sce_ng_pfs_block_t, [0x14]byte, error) {
+	if page >= fdb.NumPages() {
+		return nil, [0x14]byte{}, fmt.Errorf("page %d out of range", page)
+	}
+
+	raw := make([]byte, fdb.Header.PageSize)
+	off := headerSize + int64(page)*int64(fdb.Header.PageSize)
+	if _, err := fdb.r.ReadAt(raw, off); err != nil {
+		return nil, [0x14]byte{}, err
+	}
+
+	var block sce_ng_pfs_block_t
+	if err := binary.Read(bytes.NewReader(raw), binary.LittleEndian, &block); err != nil {
+		return nil, [0x14]byte{}, err
+	}
+
+	nEntries := uint64(block.Header.NumFiles)
+	if block.Header.Type > 0 {
+		nEntries++
+	}
+	if nEntries > uint64(order_max_avail(fdb.Header.PageSize)) {
+		return nil, [0x14]byte{}, fmt.Errorf("page %d: %d entries do not fit the page", page, nEntries)
+	}
+
+	icv := calculate_node_icv(&fdb.Header, fdb.secret, &block.Header, raw)
+	return &block, [0x14]byte(icv), nil
+}
 
-		icvValue := calculate_node_icv(&filesDB.Header, secret, &block.Header, raw_block.Bytes())
-		icv.Icv = [20]byte(icvValue)
-		filesDB.Blocks = append(filesDB.Blocks, block)
-		filesDB.PageIcvs[block.Header.Parent_page_number] = append(filesDB.PageIcvs[block.Header.Parent_page_number], icv)
+// readerSize returns the length of r if it can tell, or -1
+func readerSize(r io.ReaderAt) int64 {
+	switch r := r.(type) {
+	case interface{ Size() int64 }:
+		return r.Size()
+	case interface{ Stat() (os.FileInfo, error) }:
+		if st, err := r.Stat(); err == nil {
+			return st.Size()
+		}
 	}
+	return -1
+}
+
+func (fdb *FilesDB) loadAll() error {
+	fdb.PageIcvs = make(map[uint32][]page_icv_data)
+	pages := make(map[uint32]*sce_ng_pfs_block_t)
+	for page := uint32(0); page < fdb.NumPages(); page++ {
+		block, icvValue, err := fdb.readPage(page)
+		if err != nil {
+			return err
+		}
 
-	if !validate_hash_tree(0, filesDB.Header.Root_icv_page_number, &filesDB) {
-		panic("invalid hash tree")
+		icv := page_icv_data{
+			Offset: headerSize + int64(page)*int64(fdb.Header.PageSize),
+			Page:   page,
+			Icv:    icvValue,
+		}
+		pages[page] = block
+		fdb.PageIcvs[block.Header.Parent_page_number] = append(fdb.PageIcvs[block.Header.Parent_page_number], icv)
 	}
 
-	return &filesDB, nil
+	if _, ok := pages[fdb.Header.Root_icv_page_number]; !ok {
+		return fmt.Errorf("root page %d out of range", fdb.Header.Root_icv_page_number)
+	}
+	if !validate_hash_tree(0, fdb.Header.Root_icv_page_number, fdb.PageIcvs, pages) {
+		return errors.New("invalid hash tree")
+	}
+
+	fdb.mu.Lock()
+	fdb.pages = pages
+	fdb.mu.Unlock()
+	return nil
 }
 
-func validate_hash_tree(level int, page uint32, fdb *FilesDB) bool {
-	block := &fdb.Blocks[page]
+func validate_hash_tree(level int, page uint32, pageIcvs map[uint32][]page_icv_data, pages map[uint32]*sce_ng_pfs_block_t) bool {
+	if level > len(pages) {
+		return false
+	}
+	block := pages[page]
 
-	icvs := fdb.PageIcvs[page]
+	icvs := pageIcvs[page]
 	for _, icv := range icvs {
+		if icv.Page == page {
+			return false
+		}
 		if slices.Contains(block.FileHashes[:], icv.Icv) {
 			// OK
-			if !validate_hash_tree(level+1, icv.Page, fdb) {
+			if !validate_hash_tree(level+1, icv.Page, pageIcvs, pages) {
 				return false
 			}
 			continue
@@ -235,6 +422,20 @@ func calculate_node_icv(ngh *sce_ng_pfs_header_t, secret []byte, node_header *sc
 	return icv
 }
 
+// hmac of the first 0x160 bytes of the header with Header_icv and Rsa_sig0 zeroed
+func calculate_header_icv(ngh *sce_ng_pfs_header_t, secret []byte) []byte {
+	hdr := *ngh
+	hdr.Header_icv = [0x14]byte{}
+	hdr.Rsa_sig0 = [0x100]byte{}
+
+	var raw bytes.Buffer
+	binary.Write(&raw, binary.LittleEndian, &hdr)
+
+	h := hmac.New(sha1.New, secret)
+	h.Write(raw.Bytes()[:0x160])
+	return h.Sum(nil)
+}
+
 func icv_contract_hmac(iv, key, base0, base1 []byte) {
 	h := hmac.New(sha1.New, key)
 	h.Write(base0)
@@ -0,0 +1,184 @@
+package pfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+type countingReaderAt struct {
+	r     io.ReaderAt
+	reads []int64
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	c.reads = append(c.reads, off)
+	return c.r.ReadAt(p, off)
+}
+
+var testKlicensee = make([]byte, 16)
+
+func buildFilesDB(t testing.TB, pages int) []byte {
+	t.Helper()
+	var header sce_ng_pfs_header_t
+	copy(header.Magic[:], filesdbMagic)
+	header.Version = 3
+	header.PageSize = 0x400
+	header.TailSize = uint64(pages) * 0x400
+	header.Root_icv_page_number = 0
+
+	secret := get_secret(testKlicensee, kprx_auth_service_0x50001(testKlicensee), header.Files_salt, CRYPTO_ENGINE_CRYPTO_USE_KEYGEN, 0, 0)
+
+	blocks := make([]sce_ng_pfs_block_t, pages)
+	blocks[0].Header.Parent_page_number = 0xFFFFFFFF
+	for i := 1; i < pages; i++ {
+		blocks[i].Header.Parent_page_number = 0
+		blocks[i].Header.NumFiles = 1
+		blocks[i].FileHashes[0][0] = byte(i)
+	}
+	blocks[0].Header.NumFiles = uint32(pages - 1)
+	for i := 1; i < pages; i++ {
+		raw := encodeBlock(t, &blocks[i])
+		blocks[0].FileHashes[i-1] = [0x14]byte(calculate_node_icv(&header, secret, &blocks[i].Header, raw))
+	}
+
+	header.Root_icv = [0x14]byte(calculate_node_icv(&header, secret, &blocks[0].Header, encodeBlock(t, &blocks[0])))
+	header.Header_icv = [0x14]byte(calculate_header_icv(&header, secret))
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, &header)
+	for i := range blocks {
+		buf.Write(encodeBlock(t, &blocks[i]))
+	}
+	return buf.Bytes()
+}
+
+func encodeBlock(t testing.TB, block *sce_ng_pfs_block_t) []byte {
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, block); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestFilesDBLazy(t *testing.T) {
+	data := buildFilesDB(t, 4)
+	r := &countingReaderAt{r: bytes.NewReader(data)}
+	fdb, err := ParseFilesDB(r, testKlicensee, kprx_auth_service_0x50001(testKlicensee), ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fdb.NumPages() != 4 {
+		t.Fatalf("got %d pages", fdb.NumPages())
+	}
+	if !fdb.HeaderIcvValid || !fdb.RootIcvValid {
+		t.Fatalf("header %v root %v", fdb.HeaderIcvValid, fdb.RootIcvValid)
+	}
+	// header and root page
+	if len(r.reads) != 2 {
+		t.Fatalf("header parse did %d reads", len(r.reads))
+	}
+
+	if _, err := fdb.Page(2); err != nil {
+		t.Fatal(err)
+	}
+	// only page 2, its parent is the root
+	if len(r.reads) != 3 {
+		t.Fatalf("got %d reads after loading one page", len(r.reads))
+	}
+}
+
+func TestFilesDBTampered(t *testing.T) {
+	data := buildFilesDB(t, 3)
+	data[0x400*3+824] ^= 1 // first hash of page 2
+
+	kd := kprx_auth_service_0x50001(testKlicensee)
+	fdb, err := ParseFilesDB(bytes.NewReader(data), testKlicensee, kd, ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fdb.Page(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fdb.Page(2); err == nil {
+		t.Fatal("tampered page loaded")
+	}
+
+	if _, err := ParseFilesDB(bytes.NewReader(data), testKlicensee, kd, ParseOptions{Eager: true}); err == nil {
+		t.Fatal("eager parse accepted tampered page")
+	}
+}
+
+func TestFilesDBHeaderIcv(t *testing.T) {
+	kd := kprx_auth_service_0x50001(testKlicensee)
+	for _, tc := range []struct {
+		name   string
+		off    int
+		header bool
+		root   bool
+	}{
+		{"bt order", 0x14, false, true},
+		{"root page", 0x400 + 824, true, false},
+	} {
+		data := buildFilesDB(t, 2)
+		data[tc.off] ^= 1
+		fdb, err := ParseFilesDB(bytes.NewReader(data), testKlicensee, kd, ParseOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fdb.HeaderIcvValid != tc.header || fdb.RootIcvValid != tc.root {
+			t.Errorf("%s: header %v root %v", tc.name, fdb.HeaderIcvValid, fdb.RootIcvValid)
+		}
+		if _, err := ParseFilesDB(bytes.NewReader(data), testKlicensee, kd, ParseOptions{Eager: true}); err == nil {
+			t.Errorf("%s: eager parse accepted tampered data", tc.name)
+		}
+
+		_, err = fdb.Page(1)
+		if !tc.root && !errors.Is(err, ErrInvalidRootIcv) {
+			t.Errorf("%s: Page(1) err = %v, want ErrInvalidRootIcv", tc.name, err)
+		}
+		allow, err := ParseFilesDB(bytes.NewReader(data), testKlicensee, kd, ParseOptions{AllowInvalidRoot: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := allow.Page(1); errors.Is(err, ErrInvalidRootIcv) {
+			t.Errorf("%s: AllowInvalidRoot still refused the page", tc.name)
+		}
+	}
+}
+
+// buildFilesDBCycle has pages 1 and 2 name each other as parent and claims far more pages than it holds
+func buildFilesDBCycle(t testing.TB) []byte {
+	data := buildFilesDB(t, 3)
+	binary.LittleEndian.PutUint64(data[0x28:], 0x400*0xFFFFFFF0) // TailSize
+	binary.LittleEndian.PutUint32(data[0x400*2:], 2)
+	binary.LittleEndian.PutUint32(data[0x400*3:], 1)
+	return data
+}
+
+func TestFilesDBParentLoop(t *testing.T) {
+	kd := kprx_auth_service_0x50001(testKlicensee)
+	data := buildFilesDBCycle(t)
+
+	if _, err := ParseFilesDB(bytes.NewReader(data), testKlicensee, kd, ParseOptions{}); err == nil {
+		t.Error("page count over the file size accepted")
+	}
+
+	// without a size the page count can't be checked up front
+	fdb, err := ParseFilesDB(&countingReaderAt{r: bytes.NewReader(data)}, testKlicensee, kd, ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fdb.Page(1); err == nil || !strings.Contains(err.Error(), "parent loop") {
+		t.Errorf("Page(1) err = %v, want parent loop", err)
+	}
+
+	overflow := bytes.Clone(data)
+	binary.LittleEndian.PutUint64(overflow[0x28:], 0x400<<32)
+	if _, err := ParseFilesDB(&countingReaderAt{r: bytes.NewReader(overflow)}, testKlicensee, kd, ParseOptions{}); err == nil {
+		t.Error("page count over uint32 accepted")
+	}
+}
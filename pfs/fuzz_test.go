@@ -0,0 +1,33 @@
+package pfs
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func FuzzParseFilesDB(f *testing.F) {
+	f.Add(buildFilesDB(f, 3))
+	f.Add(buildFilesDBCycle(f))
+	kd := kprx_auth_service_0x50001(testKlicensee)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// also without a Size method, so the page count isn't bounded by the data up front
+		for _, r := range []io.ReaderAt{bytes.NewReader(data), struct{ io.ReaderAt }{bytes.NewReader(data)}} {
+			fdb, err := ParseFilesDB(r, testKlicensee, kd, ParseOptions{})
+			if err != nil {
+				continue
+			}
+			for page := uint32(0); page < min(fdb.NumPages(), 16); page++ {
+				fdb.Page(page)
+			}
+		}
+		ParseFilesDB(bytes.NewReader(data), testKlicensee, kd, ParseOptions{Eager: true})
+	})
+}
+
+func FuzzParseUnicv(f *testing.F) {
+	f.Add([]byte("SCEIRODB\x02\x00\x04\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x04\x00\x00\x00\x00\x00\x00SCEIFTBL"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ParseUnicv(bytes.NewReader(data))
+	})
+}
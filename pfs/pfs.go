@@ -1,11 +1,14 @@
 package pfs
 
 import (
+	"bytes"
+	"io"
 	"io/fs"
 )
 
 type PFS struct {
-	fs fs.FS
+	fs      fs.FS
+	filesDB fs.File
 
 	Unicv   *Unicv
 	FilesDB *FilesDB
@@ -27,6 +30,18 @@ func (p *PFS) init() (err error) {
 	if err != nil {
 		return err
 	}
+	p.filesDB = f
+
+	// pages are read lazily, fall back to memory if the fs cant seek
+	r, ok := f.(io.ReaderAt)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
 
 	klicensee := []byte{
 		0xEF, 0x3E, 0x79, 0x08, 0x49, 0x41, 0x27, 0xAE, 0x52, 0xA8, 0xEB, 0xC0, 0x30, 0xF2, 0x00, 0x7C,
@@ -34,13 +49,21 @@ func (p *PFS) init() (err error) {
 
 	klicenseeDeriv := kprx_auth_service_0x50001(klicensee)
 
-	p.FilesDB, err = ParseFilesDB(f, klicensee, klicenseeDeriv)
+	p.FilesDB, err = ParseFilesDB(r, klicensee, klicenseeDeriv, ParseOptions{})
 	if err != nil {
+		f.Close()
 		return err
 	}
 	return nil
 }
 
+func (p *PFS) Close() error {
+	if p.filesDB == nil {
+		return nil
+	}
+	return p.filesDB.Close()
+}
+
 func (*PFS) Open(name string) (fs.File, error) {
 	return nil, nil
 }
@@ -72,6 +72,9 @@ func ParseUnicv(r io.Reader) (*Unicv, error) {
 		return nil, err
 	}
 
+	if unicv.Header.BlockSize == 0 {
+		return nil, fmt.Errorf("invalid block size")
+	}
 	numBlocks := int(unicv.Header.DataSize / uint64(unicv.Header.BlockSize))
 	for i := 0; i < numBlocks; i++ {
 		var magic = make([]byte, 4)
@@ -4,10 +4,16 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"encoding/binary"
-	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/olebeck/go-pkg/sfo"
 )
 
 const (
@@ -18,6 +24,7 @@ const (
 	PKG_TYPE_PSP
 	PKG_TYPE_PSX
 	PKG_TYPE_VITA_LIVEAREA
+	PKG_TYPE_VITA_THEME
 )
 
 func mustAes(key []byte) cipher.Block {
@@ -41,11 +48,88 @@ type Pkg struct {
 	ContentID string
 
 	ContentType uint32
-	Sfo         *Sfo
-	Items       []Item
+	PkgType     int
+	Sfo         *sfo.File
+
+	r               io.ReaderAt
+	size            int64 // of r, -1 if unknown
+	header          *pkgHeader
+	totalSize       int64
+	encryptedOffset int64
+	encryptedSize   int64
+	itemOffset      int64
+	itemSize        int64
+
+	itemsMu  sync.Mutex
+	items    []Item
+	itemsErr error
+}
+
+type ReadOptions struct {
+	// only parse the header, metadata and param.sfo, the item table is decrypted on the first call to Items
+	HeadersOnly bool
+	// actual length of r, 0 detects it if r has a Size method or is a file
+	Size int64
+
+	// limits against malformed packages, 0 uses the defaults below
+	MaxItems         int
+	MaxMetaSize      int // of the metadata and param.sfo each
+	MaxNameLength    int
+	MaxItemTableSize int // bytes of the encrypted item table, read into memory at once
+
+	salvage bool // allow the package to be shorter than its header says
+}
+
+const (
+	DefaultMaxItems         = 1 << 20
+	DefaultMaxMetaSize      = 1 << 20
+	DefaultMaxNameLength    = 4096
+	DefaultMaxItemTableSize = 64 << 20
+)
+
+func (o ReadOptions) withDefaults() ReadOptions {
+	if o.MaxItems <= 0 {
+		o.MaxItems = DefaultMaxItems
+	}
+	if o.MaxMetaSize <= 0 {
+		o.MaxMetaSize = DefaultMaxMetaSize
+	}
+	if o.MaxNameLength <= 0 {
+		o.MaxNameLength = DefaultMaxNameLength
+	}
+	if o.MaxItemTableSize <= 0 {
+		o.MaxItemTableSize = DefaultMaxItemTableSize
+	}
+	return o
+}
+
+// readerSize returns the length of r if it can tell, or -1
+func readerSize(r io.ReaderAt) int64 {
+	switch r := r.(type) {
+	case interface{ Size() int64 }:
+		return r.Size()
+	case interface{ Stat() (os.FileInfo, error) }:
+		if st, err := r.Stat(); err == nil {
+			return st.Size()
+		}
+	}
+	return -1
 }
 
-type Sfo struct {
+// TitleID returns the title id part of the content id, e.g. PCSE00000
+func (p *Pkg) TitleID() string {
+	if len(p.ContentID) < 16 {
+		return ""
+	}
+	return p.ContentID[7:16]
+}
+
+// Label returns the last part of the content id, used as the dlc and theme id
+func (p *Pkg) Label() string {
+	if len(p.ContentID) < 36 {
+		return ""
+	}
+	return p.ContentID[20:36]
 }
 
 type Item struct {
@@ -53,6 +137,8 @@ type Item struct {
 	Flags         int
 	Size          int
 	io.ReadSeeker `json:"-"`
+
+	offset int64 // of the data in the package
 }
 
 func (p *Item) IsDir() bool {
@@ -63,125 +149,204 @@ func (p *Item) String() string {
 	return p.Name
 }
 
-func Read(r io.ReaderAt) (*Pkg, error) {
-	p := &Pkg{}
+const headerSize = 232
+const pkgMagic = "\x7fPKG"
 
-	const headerSize = 232
-	var header = make([]byte, headerSize)
-	_, err := r.ReadAt(header, 0)
-	if err != nil {
-		return nil, err
-	}
+// header fields needed to locate and decrypt the rest of the package
+type pkgHeader struct {
+	metaOffset uint32
+	metaCount  uint32
+	metaSize   uint32
+	itemCount  uint32
+	iv         []byte
+	keyType    byte
+
+	itemOffset uint32
+	itemSize   uint32
+	sfoOffset  uint32
+	sfoSize    uint32
 
+	mainCipher cipher.Block
+	ps3Cipher  cipher.Block
+
+	opts ReadOptions
+}
+
+func (p *Pkg) parseHeader(header []byte, opts ReadOptions) (*pkgHeader, error) {
 	copy(p.Magic[:], header[0:4])
 	p.Revision = binary.BigEndian.Uint16(header[4:6])
 	p.Type = binary.BigEndian.Uint16(header[6:8])
 
-	metaOffset := binary.BigEndian.Uint32(header[8:12])
-	metaCount := binary.BigEndian.Uint32(header[12:16])
-	metaSize := binary.BigEndian.Uint32(header[16:20])
-
-	itemCount := binary.BigEndian.Uint32(header[20:24])
-	totalSize := binary.BigEndian.Uint64(header[24:32])
-	encryptedOffset := binary.BigEndian.Uint64(header[32:40])
-	encryptedSize := binary.BigEndian.Uint64(header[40:48])
-	_ = totalSize
-	_ = encryptedSize
+	h := &pkgHeader{
+		metaOffset: binary.BigEndian.Uint32(header[8:12]),
+		metaCount:  binary.BigEndian.Uint32(header[12:16]),
+		metaSize:   binary.BigEndian.Uint32(header[16:20]),
+		itemCount:  binary.BigEndian.Uint32(header[20:24]),
+		opts:       opts,
+	}
+	p.totalSize = int64(binary.BigEndian.Uint64(header[24:32]))
+	p.encryptedOffset = int64(binary.BigEndian.Uint64(header[32:40]))
+	p.encryptedSize = int64(binary.BigEndian.Uint64(header[40:48]))
 
-	p.ContentID = string(header[48 : 48+0x24])
+	p.ContentID = strings.TrimRight(string(header[48:48+0x24]), "\x00")
 
-	digest := header[96:112]
-	iv := header[112:128]
-	keyType := header[231] & 7
-	_ = digest
+	h.iv = header[112:128]
+	h.keyType = header[231] & 7
 
-	var meta = make([]byte, metaSize)
-	_, err = r.ReadAt(meta, int64(metaOffset))
-	if err != nil {
-		return nil, err
+	if string(p.Magic[:]) != pkgMagic {
+		return nil, fmt.Errorf("wrong magic %q", p.Magic[:])
+	}
+	if int64(h.metaSize) > int64(opts.MaxMetaSize) {
+		return nil, fmt.Errorf("metadata size 0x%x over the limit", h.metaSize)
+	}
+	if int64(h.itemCount) > int64(opts.MaxItems) {
+		return nil, fmt.Errorf("%d items over the limit", h.itemCount)
 	}
+	if p.totalSize < 0 || p.encryptedOffset < 0 || p.encryptedSize < 0 || p.encryptedSize > math.MaxInt64-p.encryptedOffset {
+		return nil, errors.New("invalid encrypted section")
+	}
+	if !inRange(int64(h.metaOffset), int64(h.metaSize), p.size) {
+		return nil, fmt.Errorf("metadata at 0x%x+0x%x out of range", h.metaOffset, h.metaSize)
+	}
+	if !opts.salvage && p.size >= 0 && p.encryptedOffset+p.encryptedSize > p.size {
+		return nil, fmt.Errorf("package truncated at 0x%x, the encrypted data ends at 0x%x", p.size, p.encryptedOffset+p.encryptedSize)
+	}
+	return h, nil
+}
 
-	var itemOffset, itemSize uint32
-	var sfoOffset, sfoSize uint32
+// inRange reports if off+n lies within size, an unknown size (-1) only checks for overflow
+func inRange(off, n, size int64) bool {
+	if off < 0 || n < 0 || n > math.MaxInt64-off {
+		return false
+	}
+	return size < 0 || off+n <= size
+}
 
+func (p *Pkg) parseMeta(h *pkgHeader, meta []byte) error {
 	off := 0
-	for i := 0; i < int(metaCount); i++ {
+	for i := 0; i < int(h.metaCount); i++ {
+		if len(meta)-off < 8 {
+			return fmt.Errorf("metadata element %d out of range", i)
+		}
 		m := meta[off:]
 		metaElementType := binary.BigEndian.Uint32(m[0:4])
 		metaElementSize := binary.BigEndian.Uint32(m[4:8])
+		if int64(metaElementSize) > int64(len(m)-8) {
+			return fmt.Errorf("metadata element %d size 0x%x out of range", i, metaElementSize)
+		}
+		m = m[:8+metaElementSize]
+
+		var want uint32
+		switch metaElementType {
+		case 2:
+			want = 4
+		case 13, 14:
+			want = 8
+		}
+		if metaElementSize < want {
+			return fmt.Errorf("metadata element %d type %d too short", i, metaElementType)
+		}
+
 		switch metaElementType {
 		case 2:
 			p.ContentType = binary.BigEndian.Uint32(m[8:12])
 		case 13:
-			itemOffset = binary.BigEndian.Uint32(m[8:12])
-			itemSize = binary.BigEndian.Uint32(m[12:16])
+			h.itemOffset = binary.BigEndian.Uint32(m[8:12])
+			h.itemSize = binary.BigEndian.Uint32(m[12:16])
 		case 14:
-			sfoOffset = binary.BigEndian.Uint32(m[8:12])
-			sfoSize = binary.BigEndian.Uint32(m[12:16])
+			h.sfoOffset = binary.BigEndian.Uint32(m[8:12])
+			h.sfoSize = binary.BigEndian.Uint32(m[12:16])
 		default:
 		}
 		off += int(metaElementSize + 8)
 	}
-	_ = itemSize
-	_ = sfoOffset
-	_ = sfoSize
+	p.itemOffset = int64(h.itemOffset)
+	p.itemSize = int64(h.itemSize)
+
+	if !inRange(p.itemOffset, p.itemSize, p.encryptedSize) {
+		return fmt.Errorf("item table at 0x%x+0x%x out of range", h.itemOffset, h.itemSize)
+	}
+	if int64(h.itemCount)*32 > p.itemSize {
+		return fmt.Errorf("item table too small for %d items", h.itemCount)
+	}
+	// names are padded to 16 bytes
+	if p.itemSize > int64(h.opts.MaxItemTableSize) || p.itemSize > int64(h.itemCount)*int64(32+h.opts.MaxNameLength+16) {
+		return fmt.Errorf("item table size 0x%x over the limit", h.itemSize)
+	}
+	if h.sfoSize > 0 {
+		if int64(h.sfoSize) > int64(h.opts.MaxMetaSize) || !inRange(int64(h.sfoOffset), int64(h.sfoSize), p.size) {
+			return fmt.Errorf("param.sfo at 0x%x+0x%x out of range", h.sfoOffset, h.sfoSize)
+		}
+	}
 
-	var pkgType uint32
 	switch p.ContentType {
 	case 6:
-		pkgType = PKG_TYPE_PSX
+		p.PkgType = PKG_TYPE_PSX
 	case 7, 0xe, 0xf:
-		pkgType = PKG_TYPE_PSP
+		p.PkgType = PKG_TYPE_PSP
 	case 0x15:
-		pkgType = PKG_TYPE_VITA_APP
+		p.PkgType = PKG_TYPE_VITA_APP
 	case 0x16:
-		pkgType = PKG_TYPE_VITA_DLC
+		p.PkgType = PKG_TYPE_VITA_DLC
 	case 0x18, 0x1d:
-		pkgType = PKG_TYPE_VITA_PSM
+		p.PkgType = PKG_TYPE_VITA_PSM
 	case 23:
-		pkgType = PKG_TYPE_VITA_LIVEAREA
+		p.PkgType = PKG_TYPE_VITA_LIVEAREA
+	case 0x1f:
+		// the only theme content type used on the vita, 0x09 is a ps3 .p3t theme
+		p.PkgType = PKG_TYPE_VITA_THEME
+	case 0x09:
+		return errors.New("ps3 theme packages (ContentType 9) are not supported")
 	default:
-		return nil, fmt.Errorf("unknown ContentType %d", p.ContentType)
+		return fmt.Errorf("unknown ContentType %d", p.ContentType)
 	}
-	_ = pkgType
+	return nil
+}
 
+func (p *Pkg) parseSfo(r io.Reader) (err error) {
+	p.Sfo, err = sfo.Decode(r)
+	if err != nil {
+		return fmt.Errorf("param.sfo: %w", err)
+	}
+	// patches share the app content type
+	if p.PkgType == PKG_TYPE_VITA_APP && p.Sfo.String("CATEGORY") == "gp" {
+		p.PkgType = PKG_TYPE_VITA_PATCH
+	}
+	return nil
+}
+
+func (h *pkgHeader) initCiphers() (err error) {
 	var mainKey = make([]byte, 0x10)
-	var ps3Cipher cipher.Block
-	switch keyType {
+	switch h.keyType {
 	case 1:
 		mainKey = key_pkg_psp_key
-		ps3Cipher, err = aes.NewCipher(key_pkg_ps3_key)
+		h.ps3Cipher, err = aes.NewCipher(key_pkg_ps3_key)
 		if err != nil {
-			return nil, err
+			return err
 		}
 	case 2:
-		key_pkg_vita_2.Encrypt(mainKey, iv)
+		key_pkg_vita_2.Encrypt(mainKey, h.iv)
 	case 3:
-		key_pkg_vita_3.Encrypt(mainKey, iv)
+		key_pkg_vita_3.Encrypt(mainKey, h.iv)
 	case 4:
-		key_pkg_vita_4.Encrypt(mainKey, iv)
+		key_pkg_vita_4.Encrypt(mainKey, h.iv)
 	default:
-		return nil, errors.New("unknown key type")
-	}
-	mainCipher, err := aes.NewCipher(mainKey)
-	if err != nil {
-		return nil, err
+		return errors.New("unknown key type")
 	}
+	h.mainCipher, err = aes.NewCipher(mainKey)
+	return err
+}
 
-	fmt.Printf("iv:  %s\n", hex.EncodeToString(iv))
-	fmt.Printf("key: %s\n", hex.EncodeToString(mainKey))
-
-	// decrypted reader for the encrypted section
-	rd := newCTR(io.NewSectionReader(r, int64(encryptedOffset), int64(encryptedSize)), mainCipher, iv)
-
-	var itemData = make([]byte, itemSize)
-	rd.Seek(int64(itemOffset), io.SeekStart)
-	_, err = rd.Read(itemData)
-	if err != nil {
-		return nil, err
-	}
+// an entry of the decrypted item table
+type itemEntry struct {
+	Item
+	dataOffset uint64 // relative to the encrypted section
+	cipher     cipher.Block
+}
 
-	for i := 0; i < int(itemCount); i++ {
+func (p *Pkg) parseItems(h *pkgHeader, itemData []byte) ([]itemEntry, error) {
+	var entries []itemEntry
+	for i := 0; i < int(h.itemCount); i++ {
 		off := int64(32 * i)
 		var item Item
 
@@ -190,29 +355,142 @@ func Read(r io.ReaderAt) (*Pkg, error) {
 		dataOffset := binary.BigEndian.Uint64(itemData[off+8:])
 		dataSize := binary.BigEndian.Uint64(itemData[off+16:])
 
-		item.Size = int(dataSize)
-		if nameSize > 0xffff {
-			panic("aaaa") // broken decrypt, crash here to avoid allocating a billion bytes
+		// a broken decrypt shows up here first
+		if int64(nameSize) > int64(h.opts.MaxNameLength) {
+			return nil, fmt.Errorf("item %d: name length %d over the limit", i, nameSize)
+		}
+		if !inRange(int64(nameOffset), int64(nameSize), int64(len(itemData))) {
+			return nil, fmt.Errorf("item %d: name at 0x%x+0x%x out of range", i, nameOffset, nameSize)
 		}
+		if dataOffset > math.MaxInt64 || dataSize > math.MaxInt64 || !inRange(int64(dataOffset), int64(dataSize), p.encryptedSize) {
+			return nil, fmt.Errorf("item %d: data at 0x%x+0x%x out of range", i, dataOffset, dataSize)
+		}
+		item.Size = int(dataSize)
 
 		extra := itemData[off+24 : off+32]
 		item.Flags = int(extra[3])
 		pspType := extra[0]
 
 		item.Name = string(itemData[nameOffset : nameOffset+nameSize])
+		item.offset = p.encryptedOffset + int64(dataOffset)
 
-		var itemCipher = mainCipher
-		if pkgType == PKG_TYPE_PSP || pkgType == PKG_TYPE_PSX && pspType == 0x90 {
-			itemCipher = ps3Cipher
+		var itemCipher = h.mainCipher
+		if p.PkgType == PKG_TYPE_PSP || p.PkgType == PKG_TYPE_PSX && pspType == 0x90 {
+			itemCipher = h.ps3Cipher
 		}
+		if itemCipher == nil {
+			return nil, fmt.Errorf("item %d: no key for key type %d", i, h.keyType)
+		}
+
+		entries = append(entries, itemEntry{Item: item, dataOffset: dataOffset, cipher: itemCipher})
+	}
+	return entries, nil
+}
+
+func Read(r io.ReaderAt) (*Pkg, error) {
+	return ReadWithOptions(r, ReadOptions{})
+}
+
+// ReadHeader reads everything but the item table
+func ReadHeader(r io.ReaderAt) (*Pkg, error) {
+	return ReadWithOptions(r, ReadOptions{HeadersOnly: true})
+}
+
+func ReadWithOptions(r io.ReaderAt, opts ReadOptions) (*Pkg, error) {
+	opts = opts.withDefaults()
+	p := &Pkg{r: r, size: opts.Size}
+	if p.size <= 0 {
+		p.size = readerSize(r)
+	}
+
+	var header = make([]byte, headerSize)
+	_, err := r.ReadAt(header, 0)
+	if err != nil {
+		return nil, err
+	}
+	h, err := p.parseHeader(header, opts)
+	if err != nil {
+		return nil, err
+	}
+	p.header = h
+
+	var meta = make([]byte, h.metaSize)
+	_, err = r.ReadAt(meta, int64(h.metaOffset))
+	if err != nil {
+		return nil, err
+	}
+	if err := p.parseMeta(h, meta); err != nil {
+		return nil, err
+	}
 
-		var itemIv = make([]byte, 0x10)
-		copy(itemIv, iv)
-		incrementCounter(itemIv, int(dataOffset/16))
-		item.ReadSeeker = newCTR(io.NewSectionReader(r, int64(encryptedOffset+uint64(dataOffset)), int64(dataSize)), itemCipher, itemIv)
+	if h.sfoSize > 0 {
+		if err := p.parseSfo(io.NewSectionReader(r, int64(h.sfoOffset), int64(h.sfoSize))); err != nil {
+			return nil, err
+		}
+	}
 
-		p.Items = append(p.Items, item)
+	if err := h.initCiphers(); err != nil {
+		return nil, err
 	}
 
+	if !opts.HeadersOnly {
+		if _, err := p.Items(); err != nil {
+			return nil, err
+		}
+	}
 	return p, nil
 }
+
+// Items returns the items of the package, decrypting the item table on first use
+func (p *Pkg) Items() ([]Item, error) {
+	p.itemsMu.Lock()
+	defer p.itemsMu.Unlock()
+	if p.items == nil && p.itemsErr == nil {
+		p.items, p.itemsErr = p.readItems()
+	}
+	return p.items, p.itemsErr
+}
+
+// MarshalJSON encodes the exported fields together with the items, loading them if needed
+func (p *Pkg) MarshalJSON() ([]byte, error) {
+	items, err := p.Items()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(struct {
+		Magic       [4]byte
+		Revision    uint16
+		Type        uint16
+		ContentID   string
+		ContentType uint32
+		PkgType     int
+		Sfo         *sfo.File
+		Items       []Item
+	}{p.Magic, p.Revision, p.Type, p.ContentID, p.ContentType, p.PkgType, p.Sfo, items})
+}
+
+func (p *Pkg) readItems() ([]Item, error) {
+	h := p.header
+
+	// decrypted reader for the encrypted section
+	rd := newCTR(io.NewSectionReader(p.r, p.encryptedOffset, p.encryptedSize), h.mainCipher, h.iv)
+
+	var itemData = make([]byte, h.itemSize)
+	_, err := rd.ReadAt(itemData, int64(h.itemOffset))
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := p.parseItems(h, itemData)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []Item{}
+	for _, entry := range entries {
+		item := entry.Item
+		item.ReadSeeker = newCTR(io.NewSectionReader(p.r, item.offset, int64(item.Size)), entry.cipher, ctrIV(h.iv, entry.dataOffset))
+		items = append(items, item)
+	}
+	return items, nil
+}
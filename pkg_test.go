@@ -1,9 +1,9 @@
 package pkg_test
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"path"
@@ -30,18 +30,9 @@ func TestRead(t *testing.T) {
 
 	name := pa[:len(pa)-len(path.Ext(pa))]
 
-	for _, item := range p.Items {
-		n := path.Join("out", name, item.Name)
-		os.MkdirAll(path.Dir(n), 0777)
-		if item.IsDir() {
-			continue
-		}
-		f, err := os.Create(n)
-		if err != nil {
-			t.Fatal(err)
-		}
-		io.Copy(f, item)
-		f.Close()
+	err = pkg.Install(context.Background(), p, path.Join("out", name), pkg.InstallOptions{})
+	if err != nil {
+		t.Fatal(err)
 	}
 }
 
@@ -67,16 +58,14 @@ func TestNPS(t *testing.T) {
 			continue
 		}
 
-		resp, err := http.Get(game.PKG)
+		r, err := pkg.NewHTTPReaderAt(context.Background(), http.DefaultClient, game.PKG)
 		if err != nil {
 			t.Fatal(err)
 		}
-		r := pkg.NewStreamReader(resp.Body)
 		p, err := pkg.Read(r)
 		if err != nil {
 			t.Fatal(err)
 		}
-		resp.Body.Close()
 
 		{
 			f, err := os.Create(dataPath)
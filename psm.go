@@ -0,0 +1,21 @@
+package pkg
+
+import (
+	"fmt"
+	"io/fs"
+
+	"github.com/olebeck/go-pkg/psm"
+)
+
+// PSMFS returns the content of a psm package as laid out in psm/TITLEID (contents/ becomes RO/),
+// with PSSE protected files decrypted using the title key from the PSM license, see psm.ParseLicense
+func (p *Pkg) PSMFS(key []byte) (fs.FS, error) {
+	if p.PkgType != PKG_TYPE_VITA_PSM {
+		return nil, fmt.Errorf("package type %d is not a psm package", p.PkgType)
+	}
+	fsys, err := p.newFS(nil, p.installName)
+	if err != nil {
+		return nil, err
+	}
+	return psm.FS(fsys, key), nil
+}
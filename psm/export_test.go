@@ -0,0 +1,28 @@
+package psm
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// BuildTestPSSE encrypts data into a PSSE file
+func BuildTestPSSE(data, key []byte) []byte {
+	var h Header
+	h.Type = 1
+	copy(h.ContentID[:], "UP0000-NPOA00000_00-0000000000000000")
+	copy(h.FileName[:], "app.exe")
+	var buf bytes.Buffer
+	if err := Encrypt(&buf, h, []byte("file iv file iv "), data, key); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+// BuildTestLicense builds a license holding key in the clear
+func BuildTestLicense(contentID string, accountID uint64, key []byte) []byte {
+	out := make([]byte, LicenseSize)
+	binary.LittleEndian.PutUint64(out[0x10:], accountID)
+	copy(out[0x50:], contentID)
+	copy(out[0x120:], key)
+	return out
+}
@@ -0,0 +1,125 @@
+package psm
+
+import (
+	"encoding/binary"
+	"io"
+	"io/fs"
+	"path"
+)
+
+// FS wraps fsys so PSSE protected files read decrypted, other files are passed through.
+// Files must implement io.ReaderAt to be decrypted, directory listings report the decrypted sizes.
+func FS(fsys fs.FS, key []byte) fs.FS {
+	return &psmFS{fsys: fsys, key: key}
+}
+
+type psmFS struct {
+	fsys fs.FS
+	key  []byte
+}
+
+func (p *psmFS) Open(name string) (fs.File, error) {
+	f, err := p.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if dir, ok := f.(fs.ReadDirFile); ok {
+		if info, err := f.Stat(); err == nil && info.IsDir() {
+			return &decryptedDir{ReadDirFile: dir, fsys: p, name: name}, nil
+		}
+	}
+	ra, ok := f.(io.ReaderAt)
+	if !ok || !IsPSSE(ra) {
+		return f, nil
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	pf, err := Open(ra, info.Size(), p.key)
+	if err != nil {
+		f.Close()
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &decryptedFile{
+		File:          f,
+		info:          decryptedInfo{FileInfo: info, size: pf.Size()},
+		SectionReader: io.NewSectionReader(pf, 0, pf.Size()),
+	}, nil
+}
+
+type decryptedFile struct {
+	fs.File
+	info decryptedInfo
+	*io.SectionReader
+}
+
+func (f *decryptedFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *decryptedFile) Read(p []byte) (int, error) { return f.SectionReader.Read(p) }
+
+type decryptedInfo struct {
+	fs.FileInfo
+	size int64
+}
+
+func (i decryptedInfo) Size() int64 { return i.size }
+
+func (p *psmFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := fs.ReadDir(p.fsys, name)
+	for i, e := range entries {
+		entries[i] = p.dirEntry(name, e)
+	}
+	return entries, err
+}
+
+// dirEntry makes Info of regular files report the decrypted size, the header is read on the first call
+func (p *psmFS) dirEntry(dir string, e fs.DirEntry) fs.DirEntry {
+	if !e.Type().IsRegular() {
+		return e
+	}
+	return &decryptedEntry{DirEntry: e, fsys: p.fsys, name: path.Join(dir, e.Name())}
+}
+
+type decryptedEntry struct {
+	fs.DirEntry
+	fsys fs.FS
+	name string
+}
+
+func (e *decryptedEntry) Info() (fs.FileInfo, error) {
+	info, err := e.DirEntry.Info()
+	if err != nil {
+		return nil, err
+	}
+	f, err := e.fsys.Open(e.name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ra, ok := f.(io.ReaderAt)
+	if !ok || !IsPSSE(ra) {
+		return info, nil
+	}
+	// magic, version, then the decrypted size
+	var header [16]byte
+	if _, err := ra.ReadAt(header[:], 0); err != nil {
+		return nil, err
+	}
+	return decryptedInfo{FileInfo: info, size: int64(binary.LittleEndian.Uint64(header[8:]))}, nil
+}
+
+type decryptedDir struct {
+	fs.ReadDirFile
+	fsys *psmFS
+	name string
+}
+
+func (d *decryptedDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	entries, err := d.ReadDirFile.ReadDir(n)
+	for i, e := range entries {
+		entries[i] = d.fsys.dirEntry(d.name, e)
+	}
+	return entries, err
+}
@@ -0,0 +1,83 @@
+package psm_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/olebeck/go-pkg/psm"
+)
+
+// katVector is a real PSSE file below testdata with the sha256 of its plaintext, best one over 0x80000 bytes
+// so the signature blocks are covered. Key is the title key, with License set it is the key expected at 0x120
+// of that license and the file is decrypted with what ParseLicense returns.
+type katVector struct {
+	File    string
+	License string
+	Key     string
+	SHA256  string
+}
+
+// TestKnownAnswer decrypts the files listed in testdata/kat.json, these come from real PSM dumps
+// and are not in the repository
+func TestKnownAnswer(t *testing.T) {
+	manifest, err := os.ReadFile(filepath.Join("testdata", "kat.json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Skip("no testdata/kat.json")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vectors []katVector
+	if err := json.Unmarshal(manifest, &vectors); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range vectors {
+		t.Run(v.File, func(t *testing.T) {
+			want, err := hex.DecodeString(v.Key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			key := want
+			if v.License != "" {
+				rif, err := os.ReadFile(filepath.Join("testdata", v.License))
+				if err != nil {
+					t.Fatal(err)
+				}
+				l, err := psm.ParseLicense(rif)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if key, err = l.TitleKey(); err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(key, want) {
+					t.Fatalf("license key %x, want %x", key, want)
+				}
+			}
+
+			data, err := os.ReadFile(filepath.Join("testdata", v.File))
+			if err != nil {
+				t.Fatal(err)
+			}
+			f, err := psm.Open(bytes.NewReader(data), int64(len(data)), key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			h := sha256.New()
+			if _, err := f.WriteTo(h); err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(h.Sum(nil)); got != v.SHA256 {
+				t.Errorf("plaintext sha256 %s, want %s", got, v.SHA256)
+			}
+		})
+	}
+}
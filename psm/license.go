@@ -0,0 +1,43 @@
+package psm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const LicenseSize = 0x400
+
+// account id of the licenses written by NoPsmDrm, pkg2zip writes these as RO/License/FAKE.rif
+const FakeAccountID = 0x0123456789abcdef
+
+// ErrEncryptedLicense is returned for licenses tied to an account, their key is encrypted with a console key
+var ErrEncryptedLicense = errors.New("psm: license key is encrypted")
+
+// License is a PSM license (.rif)
+type License struct {
+	AccountID uint64
+	ContentID string
+	key       [0x10]byte
+}
+
+func ParseLicense(data []byte) (*License, error) {
+	if len(data) != LicenseSize {
+		return nil, fmt.Errorf("psm: license is 0x%x bytes, want 0x%x", len(data), LicenseSize)
+	}
+	l := &License{
+		AccountID: binary.LittleEndian.Uint64(data[0x10:]),
+		ContentID: string(bytes.TrimRight(data[0x50:0x80], "\x00")),
+	}
+	copy(l.key[:], data[0x120:0x130])
+	return l, nil
+}
+
+// TitleKey returns the key PSSE files are encrypted with, only fake licenses store it in the clear
+func (l *License) TitleKey() ([]byte, error) {
+	if l.AccountID != FakeAccountID {
+		return nil, ErrEncryptedLicense
+	}
+	return bytes.Clone(l.key[:]), nil
+}
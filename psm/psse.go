@@ -0,0 +1,281 @@
+// Package psm reads PlayStation Mobile files protected with PSSE.
+//
+// Protected files start with a 0x680 byte header followed by the data in 0x8000 byte blocks,
+// each encrypted with AES-128-CBC using the title key and the file iv xored with the block number.
+// The file iv in the header is itself encrypted with a fixed key, and every 0x80000 bytes of the file
+// start with a 0x400 byte signature that is not part of the data.
+// The md5 stored in the header is checked once a file has been read completely.
+package psm
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+)
+
+var ErrChecksum = errors.New("psm: md5 mismatch, wrong key?")
+
+const (
+	HeaderSize = 0x680
+	BlockSize  = 0x8000
+
+	signatureInterval = 0x80000
+	signatureSize     = 0x400
+)
+
+// the header iv is AES-128-CBC encrypted with these
+var psse_header_key = []byte{0x4e, 0x29, 0x8b, 0x40, 0xf5, 0x31, 0xf4, 0x69, 0xd2, 0x1f, 0x75, 0xb1, 0x33, 0xc3, 0x07, 0xbe}
+var psse_header_iv = []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}
+
+// Header is the start of a PSSE file
+type Header struct {
+	Magic     [4]byte // PSSE, or PSME for some edata files
+	Version   uint32
+	FileSize  uint64
+	Type      uint32
+	ContentID [0x2C]byte
+	MD5       [0x10]byte
+	FileName  [0x20]byte
+	IV        [0x10]byte // encrypted
+}
+
+// IsPSSE reports if r starts with a PSSE or PSME magic
+func IsPSSE(r io.ReaderAt) bool {
+	var magic [4]byte
+	if _, err := r.ReadAt(magic[:], 0); err != nil {
+		return false
+	}
+	return string(magic[:]) == "PSSE" || string(magic[:]) == "PSME"
+}
+
+// File decrypts a PSSE file block by block
+type File struct {
+	Header Header
+
+	r     io.ReaderAt
+	size  int64
+	block cipher.Block
+	iv    [0x10]byte
+}
+
+// Open reads the PSSE header of r, key is the 16 byte title key from the PSM license
+func Open(r io.ReaderAt, size int64, key []byte) (*File, error) {
+	if len(key) != 16 {
+		return nil, fmt.Errorf("psm: invalid key length %d", len(key))
+	}
+	var header [HeaderSize]byte
+	if _, err := r.ReadAt(header[:], 0); err != nil {
+		return nil, err
+	}
+
+	f := &File{r: r, size: size}
+	binary.Read(bytes.NewReader(header[:]), binary.LittleEndian, &f.Header)
+	if !IsPSSE(bytes.NewReader(header[:])) {
+		return nil, errors.New("psm: not a PSSE file")
+	}
+	if f.Header.Version != 1 {
+		return nil, fmt.Errorf("psm: unsupported version %d", f.Header.Version)
+	}
+	if f.Header.FileSize > 0 {
+		if end := fileOffset(int64(f.Header.FileSize)-1) + 1; end > size {
+			return nil, fmt.Errorf("psm: file size 0x%x exceeds data, ends at 0x%x of 0x%x", f.Header.FileSize, end, size)
+		}
+	}
+
+	headerCipher, err := aes.NewCipher(psse_header_key)
+	if err != nil {
+		return nil, err
+	}
+	cipher.NewCBCDecrypter(headerCipher, psse_header_iv).CryptBlocks(f.iv[:], f.Header.IV[:])
+
+	f.block, err = aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// fileOffset maps an offset in the encrypted data to the file, skipping the header and signatures
+func fileOffset(off int64) int64 {
+	const first = signatureInterval - HeaderSize
+	const per = signatureInterval - signatureSize
+	if off < first {
+		return HeaderSize + off
+	}
+	off -= first
+	return (off/per+1)*signatureInterval + signatureSize + off%per
+}
+
+// readData reads encrypted data at off, across signatures
+func (f *File) readData(p []byte, off int64) error {
+	for len(p) > 0 {
+		pos := fileOffset(off)
+		n := min(int64(len(p)), signatureInterval-pos%signatureInterval)
+		if pos+n > f.size {
+			return io.ErrUnexpectedEOF
+		}
+		if _, err := f.r.ReadAt(p[:n], pos); err != nil {
+			return err
+		}
+		p = p[n:]
+		off += n
+	}
+	return nil
+}
+
+// Size is the size of the decrypted data
+func (f *File) Size() int64 {
+	return int64(f.Header.FileSize)
+}
+
+func (f *File) NumBlocks() int {
+	return int((f.Size() + BlockSize - 1) / BlockSize)
+}
+
+// blockIV is the decrypted file iv with the block number xored into the first 8 bytes
+func (f *File) blockIV(i int) []byte {
+	iv := f.iv
+	var n [8]byte
+	binary.LittleEndian.PutUint64(n[:], uint64(i))
+	for j := range n {
+		iv[j] ^= n[j]
+	}
+	return iv[:]
+}
+
+// ReadBlock decrypts block i
+func (f *File) ReadBlock(i int) ([]byte, error) {
+	if i < 0 || i >= f.NumBlocks() {
+		return nil, fmt.Errorf("psm: block %d out of range", i)
+	}
+	length := int(min(BlockSize, f.Size()-int64(i)*BlockSize))
+	data := make([]byte, (length+15)&^15)
+	if err := f.readData(data, int64(i)*BlockSize); err != nil {
+		return nil, fmt.Errorf("psm: block %d: %w", i, err)
+	}
+	cipher.NewCBCDecrypter(f.block, f.blockIV(i)).CryptBlocks(data, data)
+	return data[:length], nil
+}
+
+// ReadAt reads decrypted data, random access can't check the md5
+func (f *File) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("psm: negative offset")
+	}
+	for n < len(p) {
+		if off >= f.Size() {
+			return n, io.EOF
+		}
+		block, err := f.ReadBlock(int(off / BlockSize))
+		if err != nil {
+			return n, err
+		}
+		c := copy(p[n:], block[off%BlockSize:])
+		n += c
+		off += int64(c)
+	}
+	return n, nil
+}
+
+// WriteTo writes the decrypted data to w and then checks its md5
+func (f *File) WriteTo(w io.Writer) (int64, error) {
+	h := md5.New()
+	var written int64
+	for i := 0; i < f.NumBlocks(); i++ {
+		block, err := f.ReadBlock(i)
+		if err != nil {
+			return written, err
+		}
+		h.Write(block)
+		n, err := w.Write(block)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	if !bytes.Equal(h.Sum(nil), f.Header.MD5[:]) {
+		return written, ErrChecksum
+	}
+	return written, nil
+}
+
+// Encrypt writes data as a PSSE file, h supplies the content id, file name and type and iv is the plain file iv.
+// Signatures are written as zeros.
+func Encrypt(w io.Writer, h Header, iv, data, key []byte) error {
+	if len(iv) != 0x10 {
+		return fmt.Errorf("psm: invalid iv length %d", len(iv))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	headerCipher, err := aes.NewCipher(psse_header_key)
+	if err != nil {
+		return err
+	}
+	f := &File{Header: h}
+	copy(f.Header.Magic[:], "PSSE")
+	f.Header.Version = 1
+	f.Header.FileSize = uint64(len(data))
+	f.Header.MD5 = md5.Sum(data)
+	copy(f.iv[:], iv)
+	cipher.NewCBCEncrypter(headerCipher, psse_header_iv).CryptBlocks(f.Header.IV[:], f.iv[:])
+
+	var out bytes.Buffer
+	binary.Write(&out, binary.LittleEndian, f.Header)
+	out.Write(make([]byte, HeaderSize-out.Len()))
+	for i := 0; i < f.NumBlocks(); i++ {
+		enc := make([]byte, (min(BlockSize, len(data)-i*BlockSize)+15)&^15)
+		copy(enc, data[i*BlockSize:])
+		cipher.NewCBCEncrypter(block, f.blockIV(i)).CryptBlocks(enc, enc)
+		for len(enc) > 0 {
+			if out.Len()%signatureInterval == 0 {
+				out.Write(make([]byte, signatureSize))
+			}
+			n := min(len(enc), signatureInterval-out.Len()%signatureInterval)
+			out.Write(enc[:n])
+			enc = enc[n:]
+		}
+	}
+	_, err = w.Write(out.Bytes())
+	return err
+}
+
+// Reader returns a sequential reader that fails with ErrChecksum at the end if the md5 doesn't match
+func (f *File) Reader() io.Reader {
+	return &psseReader{f: f, md5: md5.New()}
+}
+
+type psseReader struct {
+	f     *File
+	md5   hash.Hash
+	block int
+	buf   []byte
+}
+
+func (r *psseReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.block >= r.f.NumBlocks() {
+			if !bytes.Equal(r.md5.Sum(nil), r.f.Header.MD5[:]) {
+				return 0, ErrChecksum
+			}
+			return 0, io.EOF
+		}
+		data, err := r.f.ReadBlock(r.block)
+		if err != nil {
+			return 0, err
+		}
+		r.md5.Write(data)
+		r.buf = data
+		r.block++
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
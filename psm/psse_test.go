@@ -0,0 +1,185 @@
+package psm_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/olebeck/go-pkg/psm"
+)
+
+var testKey = []byte("psm title key 16")
+
+func testData() []byte {
+	data := make([]byte, psm.BlockSize*2+77)
+	for i := range data {
+		data[i] = byte(i * 13)
+	}
+	return data
+}
+
+// spans three signatures
+func testLargeData() []byte {
+	data := make([]byte, 0x180000+123)
+	for i := range data {
+		data[i] = byte(i*13 + i>>8)
+	}
+	return data
+}
+
+func TestDecrypt(t *testing.T) {
+	data := testData()
+	file := psm.BuildTestPSSE(data, testKey)
+	if !psm.IsPSSE(bytes.NewReader(file)) {
+		t.Fatal("not detected as PSSE")
+	}
+
+	f, err := psm.Open(bytes.NewReader(file), int64(len(file)), testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Size() != int64(len(data)) || f.NumBlocks() != 3 {
+		t.Fatalf("size %d, %d blocks", f.Size(), f.NumBlocks())
+	}
+
+	var buf bytes.Buffer
+	if _, err := f.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Fatal("decrypted data differs")
+	}
+	got, err := io.ReadAll(f.Reader())
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("Reader: %v", err)
+	}
+
+	part := make([]byte, 64)
+	if _, err := f.ReadAt(part, psm.BlockSize-32); err != nil || !bytes.Equal(part, data[psm.BlockSize-32:psm.BlockSize+32]) {
+		t.Errorf("ReadAt across blocks: %v", err)
+	}
+}
+
+func TestDecryptSignatures(t *testing.T) {
+	data := testLargeData()
+	file := psm.BuildTestPSSE(data, testKey)
+	if len(file) != psm.HeaderSize+len(data)+5+3*0x400 {
+		t.Fatalf("file size 0x%x", len(file))
+	}
+	f, err := psm.Open(bytes.NewReader(file), int64(len(file)), testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := f.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Fatal("decrypted data differs")
+	}
+	// 0x80000-0x680 is the first byte after the first signature
+	part := make([]byte, 64)
+	if _, err := f.ReadAt(part, 0x80000-0x680-32); err != nil || !bytes.Equal(part, data[0x80000-0x680-32:0x80000-0x680+32]) {
+		t.Errorf("ReadAt across a signature: %v", err)
+	}
+}
+
+func TestDecryptWrongKey(t *testing.T) {
+	file := psm.BuildTestPSSE(testData(), testKey)
+	f, err := psm.Open(bytes.NewReader(file), int64(len(file)), []byte("another key 16 b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteTo(io.Discard); !errors.Is(err, psm.ErrChecksum) {
+		t.Errorf("WriteTo: err = %v, want ErrChecksum", err)
+	}
+	if _, err := io.ReadAll(f.Reader()); !errors.Is(err, psm.ErrChecksum) {
+		t.Errorf("Reader: err = %v, want ErrChecksum", err)
+	}
+}
+
+func TestDecryptTruncated(t *testing.T) {
+	file := psm.BuildTestPSSE(testData(), testKey)
+	file = file[:len(file)-psm.BlockSize]
+	if _, err := psm.Open(bytes.NewReader(file), int64(len(file)), testKey); err == nil {
+		t.Error("expected error for truncated file")
+	}
+}
+
+func TestFS(t *testing.T) {
+	data := testData()
+	fsys := psm.FS(fstest.MapFS{
+		"RO/Application/app.exe": {Data: psm.BuildTestPSSE(data, testKey)},
+		"RO/Application/app.cfg": {Data: []byte("plain")},
+	}, testKey)
+
+	got, err := fs.ReadFile(fsys, "RO/Application/app.exe")
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("app.exe: %v", err)
+	}
+	info, err := fs.Stat(fsys, "RO/Application/app.exe")
+	if err != nil || info.Size() != int64(len(data)) {
+		t.Errorf("stat: %v, %v", info, err)
+	}
+	if got, err := fs.ReadFile(fsys, "RO/Application/app.cfg"); err != nil || string(got) != "plain" {
+		t.Errorf("app.cfg = %q, %v", got, err)
+	}
+
+	sizes := map[string]int64{}
+	fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		sizes[name] = info.Size()
+		return nil
+	})
+	if sizes["RO/Application/app.exe"] != int64(len(data)) || sizes["RO/Application/app.cfg"] != 5 {
+		t.Errorf("directory sizes %v", sizes)
+	}
+	dir, err := fsys.Open("RO/Application")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dir.Close()
+	entries, err := dir.(fs.ReadDirFile).ReadDir(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if info, err := e.Info(); err != nil || info.Size() != sizes["RO/Application/"+e.Name()] {
+			t.Errorf("%s: %v, %v", e.Name(), info, err)
+		}
+	}
+}
+
+func TestParseLicense(t *testing.T) {
+	const contentID = "UP0000-NPOA00000_00-0000000000000000"
+	l, err := psm.ParseLicense(psm.BuildTestLicense(contentID, psm.FakeAccountID, testKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.ContentID != contentID {
+		t.Errorf("ContentID = %q", l.ContentID)
+	}
+	if key, err := l.TitleKey(); err != nil || !bytes.Equal(key, testKey) {
+		t.Errorf("TitleKey() = %x, %v", key, err)
+	}
+
+	l, err = psm.ParseLicense(psm.BuildTestLicense(contentID, 0x1122334455667788, testKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.TitleKey(); !errors.Is(err, psm.ErrEncryptedLicense) {
+		t.Errorf("TitleKey() err = %v, want ErrEncryptedLicense", err)
+	}
+	if _, err := psm.ParseLicense(make([]byte, 0x200)); err == nil {
+		t.Error("expected error for short license")
+	}
+}
@@ -0,0 +1,73 @@
+package pkg_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/fs"
+	"testing"
+
+	"github.com/olebeck/go-pkg"
+	"github.com/olebeck/go-pkg/psm"
+)
+
+func TestPSMFS(t *testing.T) {
+	const contentID = "UP0000-NPOA00000_00-0000000000000000"
+	// fake license with the title key at 0x120
+	license := make([]byte, psm.LicenseSize)
+	binary.LittleEndian.PutUint64(license[0x10:], psm.FakeAccountID)
+	copy(license[0x50:], contentID)
+	copy(license[0x120:], "psm title key 16")
+	lic, err := psm.ParseLicense(license)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := lic.TitleKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exe := bytes.Repeat([]byte("managed code "), 5000)
+	var psse bytes.Buffer
+	if err := psm.Encrypt(&psse, psm.Header{}, []byte("file iv file iv "), exe, key); err != nil {
+		t.Fatal(err)
+	}
+
+	data := pkg.BuildTestPkg(contentID, 0x18, nil, []pkg.TestItem{
+		{Name: "contents", Flags: 4},
+		{Name: "contents/Application", Flags: 4},
+		{Name: "contents/Application/app.cfg", Data: []byte("plain")},
+		{Name: "contents/Application/app.exe", Data: psse.Bytes()},
+	})
+	p, err := pkg.Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fsys, err := p.PSMFS(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := fs.ReadFile(fsys, "RO/Application/app.exe"); err != nil || !bytes.Equal(got, exe) {
+		t.Errorf("app.exe: %d bytes, %v", len(got), err)
+	}
+	entries, err := fs.ReadDir(fsys, "RO/Application")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if info, err := e.Info(); e.Name() == "app.exe" && (err != nil || info.Size() != int64(len(exe))) {
+			t.Errorf("app.exe listed as %v, %v", info, err)
+		}
+	}
+	if got, err := fs.ReadFile(fsys, "RO/Application/app.cfg"); err != nil || string(got) != "plain" {
+		t.Errorf("app.cfg = %q, %v", got, err)
+	}
+
+	if _, err := fs.Stat(fsys, "contents"); err == nil {
+		t.Error("contents directory not renamed")
+	}
+
+	if _, err := testHomebrew(t).PSMFS(nil); err == nil {
+		t.Error("expected error for non psm package")
+	}
+}
@@ -0,0 +1,88 @@
+package pkg_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/olebeck/go-pkg"
+)
+
+type rangeRecorder struct {
+	r   io.ReaderAt
+	max int64
+}
+
+func (r *rangeRecorder) ReadAt(p []byte, off int64) (int, error) {
+	r.max = max(r.max, off+int64(len(p)))
+	return r.r.ReadAt(p, off)
+}
+
+func TestReadHeader(t *testing.T) {
+	data := pkg.BuildTestPkg("EP0000-PCSE00000_00-0000000000000000", 0x15, pkg.BuildTestSfo(map[string]string{"TITLE": "lazy"}), []pkg.TestItem{
+		{Name: "eboot.bin", Data: []byte("elf")},
+	})
+	rr := &rangeRecorder{r: bytes.NewReader(data)}
+
+	p, err := pkg.ReadHeader(rr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Sfo.String("TITLE") != "lazy" || p.TitleID() != "PCSE00000" {
+		t.Errorf("title %q, title id %q", p.Sfo.String("TITLE"), p.TitleID())
+	}
+	headerEnd := rr.max
+
+	items, err := p.Items()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Name != "eboot.bin" {
+		t.Fatalf("items: %v", items)
+	}
+	if rr.max <= headerEnd {
+		t.Errorf("item table was read before Items, header read up to 0x%x", headerEnd)
+	}
+}
+
+func TestReadItemTableLimit(t *testing.T) {
+	data := pkg.BuildTestPkg("EP0000-PCSE00000_00-0000000000000000", 0x15, nil, []pkg.TestItem{
+		{Name: "eboot.bin", Data: []byte("elf")},
+	})
+	opts := pkg.ReadOptions{MaxItemTableSize: 16}
+	if _, err := pkg.ReadWithOptions(bytes.NewReader(data), opts); err == nil {
+		t.Error("ReadWithOptions: item table over MaxItemTableSize was accepted")
+	}
+	err := pkg.StreamWithOptions(bytes.NewReader(data), opts, func(*pkg.Pkg, pkg.Item, io.Reader) error { return nil })
+	if err == nil {
+		t.Error("StreamWithOptions: item table over MaxItemTableSize was accepted")
+	}
+	if _, err := pkg.ReadWithOptions(bytes.NewReader(data), pkg.ReadOptions{}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	data := pkg.BuildTestPkg("EP0000-PCSE00000_00-0000000000000000", 0x15, nil, []pkg.TestItem{
+		{Name: "eboot.bin", Data: []byte("elf")},
+	})
+	p, err := pkg.ReadHeader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		ContentID string
+		Items     []struct{ Name string }
+	}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ContentID != "EP0000-PCSE00000_00-0000000000000000" || len(got.Items) != 1 || got.Items[0].Name != "eboot.bin" {
+		t.Errorf("json %s", b)
+	}
+}
@@ -1,21 +1,129 @@
 package pkg
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"os"
+	"slices"
+	"sync"
 )
 
+// ErrDiscarded is returned by StreamReader.ReadAt for data that fell out of the window and was not spilled
+var ErrDiscarded = errors.New("stream region was discarded")
+
+const DefaultStreamWindow = 16 << 20
+
+type StreamReaderOptions struct {
+	// bytes of the stream kept in memory, 0 uses DefaultStreamWindow
+	Window int
+	// directory for a temp file that keeps everything older than the window,
+	// without it those regions are discarded
+	SpillDir string
+}
+
+// StreamReader turns a forward only stream into an io.ReaderAt.
+// It holds the most recent Window bytes in memory and optionally spills older data to a temp file.
 type StreamReader struct {
+	mu sync.Mutex
+
 	r          io.Reader
-	readerRead int64
-	buf        bytes.Buffer
+	readerRead int64 // bytes read from r
+	err        error // sticky error from r
+
+	window   int
+	buf      []byte // buf[head:] holds data in [bufStart, readerRead)
+	head     int
+	bufStart int64
+
+	spill *os.File // data in [0, bufStart) when spilling
 }
 
 func NewStreamReader(r io.Reader) *StreamReader {
-	return &StreamReader{
-		r: r,
+	sr, _ := NewStreamReaderOptions(r, StreamReaderOptions{})
+	return sr
+}
+
+func NewStreamReaderOptions(r io.Reader, opts StreamReaderOptions) (*StreamReader, error) {
+	sr := &StreamReader{
+		r:      r,
+		window: opts.Window,
+	}
+	if sr.window <= 0 {
+		sr.window = DefaultStreamWindow
+	}
+	if opts.SpillDir != "" {
+		f, err := os.CreateTemp(opts.SpillDir, "pkg-stream-*")
+		if err != nil {
+			return nil, err
+		}
+		sr.spill = f
+	}
+	return sr, nil
+}
+
+// Close removes the spill file
+func (r *StreamReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.spill == nil {
+		return nil
+	}
+	name := r.spill.Name()
+	err := errors.Join(r.spill.Close(), os.Remove(name))
+	r.spill = nil
+	return err
+}
+
+// fill reads from the stream until end, keeping everything from keep on in the buffer
+func (r *StreamReader) fill(end, keep int64) error {
+	for r.readerRead < end && r.err == nil {
+		if err := r.trim(min(r.readerRead-int64(r.window), keep)); err != nil {
+			return err
+		}
+
+		chunk := int(min(end-r.readerRead, 64*1024))
+		r.reserve(chunk)
+		n := len(r.buf)
+		read, err := io.ReadFull(r.r, r.buf[n:n+chunk])
+		r.buf = r.buf[:n+read]
+		r.readerRead += int64(read)
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		r.err = err
 	}
+	return nil
+}
+
+// reserve makes room for size more bytes after buf,
+// the dropped prefix is only moved out once it is at least half the buffer so every byte is copied a bounded number of times
+func (r *StreamReader) reserve(size int) {
+	if cap(r.buf)-len(r.buf) >= size {
+		return
+	}
+	if r.head > 0 && r.head >= len(r.buf)/2 {
+		n := copy(r.buf, r.buf[r.head:])
+		r.buf = r.buf[:n]
+		r.head = 0
+	}
+	r.buf = slices.Grow(r.buf, size)
+}
+
+// trim drops buffered data before off, spilling it if enabled
+func (r *StreamReader) trim(off int64) error {
+	drop := int(off - r.bufStart)
+	if drop <= 0 {
+		return nil
+	}
+	if r.spill != nil {
+		if _, err := r.spill.WriteAt(r.buf[r.head:r.head+drop], r.bufStart); err != nil {
+			return err
+		}
+	}
+	r.head += drop
+	r.bufStart = off
+	return nil
 }
 
 func (r *StreamReader) ReadAt(p []byte, off int64) (n int, err error) {
@@ -23,34 +131,32 @@ func (r *StreamReader) ReadAt(p []byte, off int64) (n int, err error) {
 		return 0, fmt.Errorf("negative offset")
 	}
 
-	if r.readerRead < off {
-		// skp
-		io.CopyN(&r.buf, r.r, off-r.readerRead)
-		r.readerRead = off
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	end := off + int64(len(p))
+	if err := r.fill(end, off); err != nil {
+		return 0, err
 	}
 
-	// if buffer already has some data
-	if r.buf.Len()-int(off) > 0 {
-		n = copy(p, r.buf.Bytes()[off:])
-		p = p[n:]
-		if len(p) == 0 {
-			return
+	if off < r.bufStart {
+		if r.spill == nil {
+			return 0, fmt.Errorf("read at 0x%x: %w, window starts at 0x%x", off, ErrDiscarded, r.bufStart)
+		}
+		n, err = r.spill.ReadAt(p[:min(int64(len(p)), r.bufStart-off)], off)
+		if err != nil {
+			return n, err
 		}
 	}
 
-	po := p
-
-read:
-	n2, err := r.r.Read(p)
-	r.readerRead += int64(n2)
-	n += n2
-	r.buf.Write(p[:n2])
-	if err != nil {
-		return n, err
+	if n < len(p) && off+int64(n) < r.readerRead {
+		n += copy(p[n:], r.buf[r.head+int(off+int64(n)-r.bufStart):])
 	}
-	if n < len(po) {
-		p = p[n2:]
-		goto read
+	if n < len(p) {
+		if r.err != nil {
+			return n, r.err
+		}
+		return n, io.EOF
 	}
-	return
+	return n, nil
 }
@@ -0,0 +1,94 @@
+package pkg_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/olebeck/go-pkg"
+)
+
+func testStreamData() []byte {
+	data := make([]byte, 300000)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	return data
+}
+
+func TestStreamReaderWindow(t *testing.T) {
+	data := testStreamData()
+	r, err := pkg.NewStreamReaderOptions(bytes.NewReader(data), pkg.StreamReaderOptions{Window: 4096})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 100)
+	if _, err := r.ReadAt(buf, 200000); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, data[200000:200100]) {
+		t.Error("content mismatch")
+	}
+	// still inside the window
+	if _, err := r.ReadAt(buf, 200000-2000); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ReadAt(buf, 0); !errors.Is(err, pkg.ErrDiscarded) {
+		t.Errorf("got %v, want ErrDiscarded", err)
+	}
+
+	n, err := r.ReadAt(buf, int64(len(data)-10))
+	if n != 10 || err != io.EOF {
+		t.Errorf("read at end: %d, %v", n, err)
+	}
+}
+
+func TestStreamReaderSpill(t *testing.T) {
+	data := testStreamData()
+	r, err := pkg.NewStreamReaderOptions(bytes.NewReader(data), pkg.StreamReaderOptions{Window: 4096, SpillDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for off := int64(i * 1000); off < int64(len(data))-5000; off += 37000 {
+				buf := make([]byte, 5000)
+				if _, err := r.ReadAt(buf, off); err != nil {
+					t.Error(err)
+					return
+				}
+				if !bytes.Equal(buf, data[off:off+5000]) {
+					t.Errorf("content mismatch at %d", off)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestStreamReaderSequential(t *testing.T) {
+	data := bytes.Repeat(testStreamData(), 10)
+	r, err := pkg.NewStreamReaderOptions(bytes.NewReader(data), pkg.StreamReaderOptions{Window: 64 * 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 1000)
+	for off := 0; off < len(data); off += len(buf) {
+		n, err := r.ReadAt(buf, int64(off))
+		if err != nil && err != io.EOF {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf[:n], data[off:off+n]) {
+			t.Fatalf("content mismatch at %d", off)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ExtractManifestName is the file ExtractOptions.Resume keeps inside the destination
+const ExtractManifestName = ".pkg-manifest.jsonl"
+
+// manifestEntry is one line of the manifest, the first line only holds the content id
+type manifestEntry struct {
+	ContentID string `json:"content_id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Size      int64  `json:"size,omitempty"`
+	SHA256    string `json:"sha256,omitempty"`
+}
+
+type extractManifest struct {
+	mu      sync.Mutex
+	f       *os.File
+	entries map[string]manifestEntry
+}
+
+// openManifest loads the manifest in dst, starting a new one if it is missing or belongs to another package
+func openManifest(dst, contentID string) (*extractManifest, error) {
+	name := filepath.Join(dst, ExtractManifestName)
+	if st, err := os.Lstat(name); err == nil && st.Mode()&fs.ModeSymlink != 0 {
+		return nil, fmt.Errorf("%w: %s", ErrSymlink, name)
+	}
+
+	m := &extractManifest{entries: make(map[string]manifestEntry)}
+	data, err := os.ReadFile(name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, 1<<20)
+	valid := false
+	for sc.Scan() {
+		var e manifestEntry
+		// a line cut off by an interruption is simply dropped
+		if json.Unmarshal(sc.Bytes(), &e) != nil {
+			continue
+		}
+		if !valid {
+			if e.ContentID != contentID {
+				break
+			}
+			valid = true
+			continue
+		}
+		m.entries[e.Name] = e
+	}
+
+	if valid {
+		m.f, err = os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0666)
+		if err != nil {
+			return nil, err
+		}
+		// make sure a cut off line doesn't swallow the next entry
+		if len(data) > 0 && data[len(data)-1] != '\n' {
+			if _, err := m.f.Write([]byte{'\n'}); err != nil {
+				m.f.Close()
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+
+	m.entries = make(map[string]manifestEntry)
+	m.f, err = os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.write(manifestEntry{ContentID: contentID}); err != nil {
+		m.f.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *extractManifest) write(e manifestEntry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = m.f.Write(append(line, '\n'))
+	return err
+}
+
+func (m *extractManifest) record(name string, size int64, sum []byte) error {
+	e := manifestEntry{Name: name, Size: size, SHA256: hex.EncodeToString(sum)}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[name] = e
+	return m.write(e)
+}
+
+// extracted reports if root/file.name still has the content recorded in the manifest
+func (m *extractManifest) extracted(root string, file extractFile) bool {
+	if m == nil {
+		return false
+	}
+	m.mu.Lock()
+	e, ok := m.entries[file.name]
+	m.mu.Unlock()
+	if !ok || e.Size != int64(file.Size) {
+		return false
+	}
+
+	name := filepath.Join(root, filepath.FromSlash(file.name))
+	st, err := os.Lstat(name)
+	if err != nil || !st.Mode().IsRegular() || st.Size() != e.Size {
+		return false
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return false
+	}
+	return hex.EncodeToString(h.Sum(nil)) == e.SHA256
+}
+
+func (m *extractManifest) Close() error {
+	return m.f.Close()
+}
@@ -0,0 +1,110 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+type ItemState int
+
+const (
+	ItemComplete ItemState = iota
+	ItemPartial
+	ItemMissing
+)
+
+func (s ItemState) String() string {
+	switch s {
+	case ItemComplete:
+		return "complete"
+	case ItemPartial:
+		return "partial"
+	case ItemMissing:
+		return "missing"
+	}
+	return fmt.Sprintf("ItemState(%d)", int(s))
+}
+
+type SalvageItem struct {
+	Item
+	State     ItemState
+	Offset    int64 // of the item data in the package
+	Available int64 // bytes of the item data present
+}
+
+type SalvageReport struct {
+	Size      int64 // bytes present
+	TotalSize int64 // bytes the header says the package has
+	Items     []SalvageItem
+}
+
+// Truncated reports if the package is shorter than its header says
+func (r *SalvageReport) Truncated() bool {
+	return r.Size < r.TotalSize
+}
+
+// ResumeOffset is where an interrupted download continues
+func (r *SalvageReport) ResumeOffset() int64 {
+	return r.Size
+}
+
+// Missing is the number of bytes still needed to complete the package
+func (r *SalvageReport) Missing() int64 {
+	return max(r.TotalSize-r.Size, 0)
+}
+
+// Complete returns the items that are fully present and can be extracted
+func (r *SalvageReport) Complete() []Item {
+	var items []Item
+	for _, item := range r.Items {
+		if item.State == ItemComplete {
+			items = append(items, item.Item)
+		}
+	}
+	return items
+}
+
+// Salvage reads a possibly truncated package of size bytes and classifies its items by how much of their data is present.
+// The header, metadata and item table have to be intact, the item readers of partial items end at the truncation.
+func Salvage(r io.ReaderAt, size int64) (*Pkg, *SalvageReport, error) {
+	if size <= 0 {
+		size = readerSize(r)
+	}
+	if size < 0 {
+		return nil, nil, errors.New("salvage: unknown package size")
+	}
+
+	p, err := ReadWithOptions(r, ReadOptions{Size: size, HeadersOnly: true, salvage: true})
+	if err != nil {
+		return nil, nil, fmt.Errorf("salvage: %w", err)
+	}
+	if tableEnd := p.encryptedOffset + p.itemOffset + p.itemSize; tableEnd > size {
+		return nil, nil, fmt.Errorf("salvage: package truncated at 0x%x, the item table ends at 0x%x", size, tableEnd)
+	}
+	items, err := p.Items()
+	if err != nil {
+		return nil, nil, fmt.Errorf("salvage: %w", err)
+	}
+
+	report := &SalvageReport{
+		Size:      size,
+		TotalSize: p.totalSize,
+	}
+	for _, item := range items {
+		s := SalvageItem{Item: item, Offset: item.offset, Available: int64(item.Size)}
+		end := item.offset + int64(item.Size)
+		switch {
+		case item.Size == 0 || end <= size:
+			s.State = ItemComplete
+		case item.offset >= size:
+			s.State = ItemMissing
+			s.Available = 0
+		default:
+			s.State = ItemPartial
+			s.Available = size - item.offset
+		}
+		report.Items = append(report.Items, s)
+	}
+	return p, report, nil
+}
@@ -0,0 +1,48 @@
+package pkg_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/olebeck/go-pkg"
+)
+
+func TestSalvage(t *testing.T) {
+	items := []pkg.TestItem{
+		{Name: "sce_sys", Flags: 4},
+		{Name: "a.bin", Data: bytes.Repeat([]byte("a"), 1000)},
+		{Name: "b.bin", Data: bytes.Repeat([]byte("b"), 1000)},
+		{Name: "c.bin", Data: bytes.Repeat([]byte("c"), 1000)},
+	}
+	data := pkg.BuildTestPkg("EP0000-PCSE00000_00-0000000000000000", 0x15, nil, items)
+
+	// drop the tail, c.bin and the second half of b.bin, item data is padded to 16 bytes
+	missing := 0x20 + 1008 + 8 + 500
+	_, report, err := pkg.Salvage(bytes.NewReader(data[:len(data)-missing]), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Truncated() || report.Missing() != int64(missing) || report.ResumeOffset() != int64(len(data)-missing) {
+		t.Errorf("truncated %v, missing %d", report.Truncated(), report.Missing())
+	}
+
+	want := map[string]pkg.ItemState{"sce_sys": pkg.ItemComplete, "a.bin": pkg.ItemComplete, "b.bin": pkg.ItemPartial, "c.bin": pkg.ItemMissing}
+	for _, item := range report.Items {
+		if item.State != want[item.Name] {
+			t.Errorf("%s: %s, want %s", item.Name, item.State, want[item.Name])
+		}
+		if item.Name == "b.bin" && item.Available != 500 {
+			t.Errorf("b.bin: %d bytes available", item.Available)
+		}
+	}
+
+	complete := report.Complete()
+	if len(complete) != 2 {
+		t.Fatalf("%d complete items", len(complete))
+	}
+	got, err := io.ReadAll(complete[1])
+	if err != nil || !bytes.Equal(got, items[1].Data) {
+		t.Errorf("a.bin: %v", err)
+	}
+}
@@ -0,0 +1,258 @@
+package sfo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	FormatUTF8S = 0x0004 // utf8 without terminating NUL
+	FormatUTF8  = 0x0204
+	FormatInt32 = 0x0404
+)
+
+const sfoMagic = "\x00PSF"
+
+type sfo_header_t struct {
+	Magic          [4]byte
+	Version        uint32
+	KeyTableStart  uint32
+	DataTableStart uint32
+	TablesEntries  uint32
+}
+
+type sfo_index_t struct {
+	KeyOffset  uint16
+	DataFmt    uint16
+	DataLen    uint32
+	DataMaxLen uint32
+	DataOffset uint32
+}
+
+type Entry struct {
+	Key    string
+	Format uint16
+	MaxLen uint32
+	Data   []byte
+}
+
+// String returns the value of a utf8 entry without the terminating NUL
+func (e *Entry) String() string {
+	return string(bytes.TrimRight(e.Data, "\x00"))
+}
+
+func (e *Entry) Int() uint32 {
+	if len(e.Data) < 4 {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(e.Data)
+}
+
+type File struct {
+	Version uint32
+	Entries []Entry
+}
+
+func Decode(r io.Reader) (*File, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var header sfo_header_t
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	if string(header.Magic[:]) != sfoMagic {
+		return nil, errors.New("not a sfo file")
+	}
+	if header.KeyTableStart > uint32(len(data)) || header.DataTableStart > uint32(len(data)) {
+		return nil, errors.New("sfo tables out of range")
+	}
+
+	headerSize := binary.Size(header)
+	indexSize := binary.Size(sfo_index_t{})
+	if uint64(header.TablesEntries)*uint64(indexSize) > uint64(len(data)-headerSize) {
+		return nil, errors.New("sfo index out of range")
+	}
+
+	keys := data[header.KeyTableStart:]
+	values := data[header.DataTableStart:]
+
+	f := &File{
+		Version: header.Version,
+		Entries: make([]Entry, 0, header.TablesEntries),
+	}
+	for i := 0; i < int(header.TablesEntries); i++ {
+		var index sfo_index_t
+		binary.Read(bytes.NewReader(data[headerSize+i*indexSize:]), binary.LittleEndian, &index)
+
+		if int(index.KeyOffset) >= len(keys) {
+			return nil, fmt.Errorf("sfo entry %d: key out of range", i)
+		}
+		key, _, ok := bytes.Cut(keys[index.KeyOffset:], []byte{0})
+		if !ok {
+			return nil, fmt.Errorf("sfo entry %d: unterminated key", i)
+		}
+		if uint64(index.DataOffset)+uint64(index.DataLen) > uint64(len(values)) {
+			return nil, fmt.Errorf("sfo entry %s: data out of range", key)
+		}
+		if index.DataLen > index.DataMaxLen {
+			return nil, fmt.Errorf("sfo entry %s: length %d exceeds max length %d", key, index.DataLen, index.DataMaxLen)
+		}
+
+		f.Entries = append(f.Entries, Entry{
+			Key:    string(key),
+			Format: index.DataFmt,
+			MaxLen: index.DataMaxLen,
+			Data:   bytes.Clone(values[index.DataOffset : index.DataOffset+index.DataLen]),
+		})
+	}
+	return f, nil
+}
+
+func (f *File) Get(key string) *Entry {
+	for i := range f.Entries {
+		if f.Entries[i].Key == key {
+			return &f.Entries[i]
+		}
+	}
+	return nil
+}
+
+func (f *File) String(key string) string {
+	e := f.Get(key)
+	if e == nil {
+		return ""
+	}
+	return e.String()
+}
+
+func (f *File) Int(key string) (uint32, bool) {
+	e := f.Get(key)
+	if e == nil || e.Format != FormatInt32 {
+		return 0, false
+	}
+	return e.Int(), true
+}
+
+// Set adds or replaces an entry, a new entry goes before the first entry with a greater key
+// so sorted files stay sorted and files in any other order keep it.
+// MaxLen is raised to fit the data if needed.
+func (f *File) Set(e Entry) {
+	if need := align4(uint32(len(e.Data))); e.MaxLen < need {
+		e.MaxLen = need
+	}
+	if existing := f.Get(e.Key); existing != nil {
+		*existing = e
+		return
+	}
+	i := len(f.Entries)
+	for j := range f.Entries {
+		if f.Entries[j].Key > e.Key {
+			i = j
+			break
+		}
+	}
+	f.Entries = append(f.Entries, Entry{})
+	copy(f.Entries[i+1:], f.Entries[i:])
+	f.Entries[i] = e
+}
+
+// SetString sets a utf8 value, an existing entry keeps its format and max length if the value fits
+func (f *File) SetString(key, value string) {
+	e := Entry{Key: key, Format: FormatUTF8, Data: append([]byte(value), 0)}
+	if existing := f.Get(key); existing != nil && existing.Format != FormatInt32 {
+		e.Format, e.MaxLen = existing.Format, existing.MaxLen
+		if e.Format == FormatUTF8S {
+			e.Data = []byte(value)
+		}
+	}
+	f.Set(e)
+}
+
+func (f *File) SetInt(key string, value uint32) {
+	f.Set(Entry{Key: key, Format: FormatInt32, MaxLen: 4, Data: binary.LittleEndian.AppendUint32(nil, value)})
+}
+
+func (f *File) Delete(key string) {
+	for i := range f.Entries {
+		if f.Entries[i].Key == key {
+			f.Entries = append(f.Entries[:i], f.Entries[i+1:]...)
+			return
+		}
+	}
+}
+
+func align4(n uint32) uint32 {
+	return (n + 3) &^ 3
+}
+
+// Encode writes f in entry order with the key table padded to 4 bytes and every value padded to its MaxLen
+func Encode(w io.Writer, f *File) error {
+	headerSize := uint32(binary.Size(sfo_header_t{}))
+	indexSize := uint32(binary.Size(sfo_index_t{}))
+
+	var keys, values bytes.Buffer
+	index := make([]sfo_index_t, len(f.Entries))
+	for i, e := range f.Entries {
+		if e.Key == "" || bytes.IndexByte([]byte(e.Key), 0) >= 0 {
+			return fmt.Errorf("sfo entry %d: invalid key %q", i, e.Key)
+		}
+		if uint32(len(e.Data)) > e.MaxLen {
+			return fmt.Errorf("sfo entry %s: %d bytes exceed max length %d", e.Key, len(e.Data), e.MaxLen)
+		}
+		if keys.Len() > 0xffff {
+			return errors.New("sfo key table too large")
+		}
+		index[i] = sfo_index_t{
+			KeyOffset:  uint16(keys.Len()),
+			DataFmt:    e.Format,
+			DataLen:    uint32(len(e.Data)),
+			DataMaxLen: e.MaxLen,
+			DataOffset: uint32(values.Len()),
+		}
+		keys.WriteString(e.Key)
+		keys.WriteByte(0)
+		values.Write(e.Data)
+		values.Write(make([]byte, e.MaxLen-uint32(len(e.Data))))
+	}
+	keys.Write(make([]byte, align4(uint32(keys.Len()))-uint32(keys.Len())))
+
+	version := f.Version
+	if version == 0 {
+		version = 0x0101
+	}
+	header := sfo_header_t{
+		Version:       version,
+		KeyTableStart: headerSize + indexSize*uint32(len(index)),
+		TablesEntries: uint32(len(index)),
+	}
+	copy(header.Magic[:], sfoMagic)
+	header.DataTableStart = header.KeyTableStart + uint32(keys.Len())
+
+	var out bytes.Buffer
+	binary.Write(&out, binary.LittleEndian, header)
+	binary.Write(&out, binary.LittleEndian, index)
+	out.Write(keys.Bytes())
+	out.Write(values.Bytes())
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// MarshalJSON encodes the entries as an object of key to string or number
+func (f *File) MarshalJSON() ([]byte, error) {
+	m := make(map[string]any, len(f.Entries))
+	for _, e := range f.Entries {
+		if e.Format == FormatInt32 {
+			m[e.Key] = e.Int()
+		} else {
+			m[e.Key] = e.String()
+		}
+	}
+	return json.Marshal(m)
+}
@@ -0,0 +1,145 @@
+package sfo_test
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/olebeck/go-pkg/sfo"
+)
+
+func testFile() *sfo.File {
+	f := &sfo.File{Version: 0x0101}
+	f.SetString("TITLE", "Homebrew")
+	f.SetString("APP_VER", "01.00")
+	f.SetString("CATEGORY", "gd")
+	f.SetInt("ATTRIBUTE", 0x8000)
+	f.Set(sfo.Entry{Key: "STITLE", Format: sfo.FormatUTF8, MaxLen: 0x34, Data: []byte("Hb\x00")})
+	f.Set(sfo.Entry{Key: "CONTENT_ID", Format: sfo.FormatUTF8S, MaxLen: 0x30, Data: []byte("EP0000-PCSE00000_00-0000000000000000")})
+	return f
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := sfo.Encode(&buf, testFile()); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	f, err := sfo.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := make([]string, len(f.Entries))
+	for i, e := range f.Entries {
+		keys[i] = e.Key
+	}
+	if !slices.IsSorted(keys) {
+		t.Errorf("keys not sorted: %q", keys)
+	}
+	if f.String("TITLE") != "Homebrew" || f.String("CONTENT_ID") != "EP0000-PCSE00000_00-0000000000000000" {
+		t.Errorf("unexpected values %+v", f.Entries)
+	}
+	if v, ok := f.Int("ATTRIBUTE"); !ok || v != 0x8000 {
+		t.Errorf("ATTRIBUTE = %x, %v", v, ok)
+	}
+	if e := f.Get("STITLE"); e.MaxLen != 0x34 {
+		t.Errorf("STITLE max length %d", e.MaxLen)
+	}
+
+	// re-encoding what was decoded gives the same bytes
+	var again bytes.Buffer
+	if err := sfo.Encode(&again, f); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again.Bytes(), data) {
+		t.Error("re-encoded file differs")
+	}
+}
+
+func TestEncodeLayout(t *testing.T) {
+	var buf bytes.Buffer
+	if err := sfo.Encode(&buf, testFile()); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	keyStart := int(data[8]) | int(data[9])<<8
+	dataStart := int(data[12]) | int(data[13])<<8
+	if keyStart != 20+16*6 || dataStart%4 != 0 {
+		t.Errorf("key table at %d, data table at %d", keyStart, dataStart)
+	}
+	for i := 0; i < 6; i++ {
+		index := data[20+16*i:]
+		maxLen := int(index[8]) | int(index[9])<<8
+		offset := int(index[12]) | int(index[13])<<8
+		if maxLen%4 != 0 || offset%4 != 0 {
+			t.Errorf("entry %d: max length %d at offset %d not aligned", i, maxLen, offset)
+		}
+	}
+}
+
+func TestEdit(t *testing.T) {
+	f := testFile()
+	f.SetString("TITLE", "A much longer title than before")
+	f.SetString("CONTENT_ID", "EP0000-PCSE00001_00-0000000000000000")
+	f.Delete("CATEGORY")
+
+	if e := f.Get("TITLE"); e.MaxLen < uint32(len(e.Data)) || e.String() != "A much longer title than before" {
+		t.Errorf("TITLE = %+v", e)
+	}
+	if e := f.Get("CONTENT_ID"); e.Format != sfo.FormatUTF8S || e.MaxLen != 0x30 || len(e.Data) != 36 {
+		t.Errorf("CONTENT_ID = %+v", e)
+	}
+	if f.Get("CATEGORY") != nil {
+		t.Error("CATEGORY not deleted")
+	}
+
+	var buf bytes.Buffer
+	if err := sfo.Encode(&buf, f); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sfo.Decode(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	f.Get("TITLE").MaxLen = 4
+	if err := sfo.Encode(&buf, f); err == nil {
+		t.Error("expected error for data exceeding max length")
+	}
+}
+
+func TestSetUnsorted(t *testing.T) {
+	// decoded files keep their entry order, which need not be sorted
+	f := &sfo.File{Entries: []sfo.Entry{
+		{Key: "TITLE", Format: sfo.FormatUTF8, MaxLen: 4, Data: []byte("a\x00")},
+		{Key: "APP_VER", Format: sfo.FormatUTF8, MaxLen: 8, Data: []byte("01.00\x00")},
+		{Key: "CATEGORY", Format: sfo.FormatUTF8, MaxLen: 4, Data: []byte("gd\x00")},
+	}}
+	f.SetString("APP_VER", "02.00")
+	f.SetInt("ATTRIBUTE", 1)
+
+	var keys []string
+	for _, e := range f.Entries {
+		keys = append(keys, e.Key)
+	}
+	if want := []string{"ATTRIBUTE", "TITLE", "APP_VER", "CATEGORY"}; !slices.Equal(keys, want) {
+		t.Errorf("keys %v, want %v", keys, want)
+	}
+	if f.String("APP_VER") != "02.00" {
+		t.Errorf("APP_VER = %q", f.String("APP_VER"))
+	}
+}
+
+func TestDecodeOverMaxLen(t *testing.T) {
+	var buf bytes.Buffer
+	if err := sfo.Encode(&buf, testFile()); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	// shrink the max length of the first entry below its data length
+	data[20+8] = 1
+	data[20+9] = 0
+	if _, err := sfo.Decode(bytes.NewReader(data)); err == nil {
+		t.Error("entry longer than its max length was accepted")
+	}
+}
@@ -0,0 +1,122 @@
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"crypto/cipher"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// positionReader keeps track of how far into the package the stream is
+type positionReader struct {
+	r   *bufio.Reader
+	pos int64
+}
+
+func (r *positionReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.pos += int64(n)
+	return n, err
+}
+
+// skipTo discards everything up to off
+func (r *positionReader) skipTo(off int64) error {
+	if off < r.pos {
+		return fmt.Errorf("offset 0x%x is behind the stream position 0x%x", off, r.pos)
+	}
+	_, err := io.CopyN(io.Discard, r, off-r.pos)
+	return err
+}
+
+func (r *positionReader) readAt(off int64, size int) ([]byte, error) {
+	if err := r.skipTo(off); err != nil {
+		return nil, err
+	}
+	var buf = make([]byte, size)
+	_, err := io.ReadFull(r, buf)
+	return buf, err
+}
+
+// Stream reads a package front to back in a single pass, calling fn with every item in the order their data is stored.
+// Only the current item is held, the reader passed to fn decrypts on the fly and is only valid until fn returns.
+// fn gets the same Pkg every call, its header, param.sfo and Items are filled in but it can't open items itself,
+// neither the Pkg items nor the Item passed to fn have a ReadSeeker of their own.
+func Stream(r io.Reader, fn func(*Pkg, Item, io.Reader) error) error {
+	return StreamWithOptions(r, ReadOptions{}, fn)
+}
+
+// StreamWithOptions is Stream with limits from opts, HeadersOnly and Size are ignored
+func StreamWithOptions(r io.Reader, opts ReadOptions, fn func(*Pkg, Item, io.Reader) error) error {
+	pr := &positionReader{r: bufio.NewReaderSize(r, 64*1024)}
+	p := &Pkg{size: -1}
+
+	header, err := pr.readAt(0, headerSize)
+	if err != nil {
+		return err
+	}
+	h, err := p.parseHeader(header, opts.withDefaults())
+	if err != nil {
+		return err
+	}
+
+	meta, err := pr.readAt(int64(h.metaOffset), int(h.metaSize))
+	if err != nil {
+		return err
+	}
+	if err := p.parseMeta(h, meta); err != nil {
+		return err
+	}
+
+	if h.sfoSize > 0 {
+		paramSfo, err := pr.readAt(int64(h.sfoOffset), int(h.sfoSize))
+		if err != nil {
+			return fmt.Errorf("param.sfo: %w", err)
+		}
+		if err := p.parseSfo(bytes.NewReader(paramSfo)); err != nil {
+			return err
+		}
+	}
+
+	if err := h.initCiphers(); err != nil {
+		return err
+	}
+
+	itemData, err := pr.readAt(p.encryptedOffset+int64(h.itemOffset), int(h.itemSize))
+	if err != nil {
+		return err
+	}
+	ctrStreamAt(h.mainCipher, h.iv, int64(h.itemOffset)).XORKeyStream(itemData, itemData)
+
+	entries, err := p.parseItems(h, itemData)
+	if err != nil {
+		return err
+	}
+	p.items = make([]Item, len(entries))
+	for i, entry := range entries {
+		p.items[i] = entry.Item
+	}
+	slices.SortStableFunc(entries, func(a, b itemEntry) int {
+		return cmp.Compare(a.offset, b.offset)
+	})
+
+	for _, entry := range entries {
+		// empty items like directories may point anywhere
+		if entry.Size > 0 {
+			if err := pr.skipTo(entry.offset); err != nil {
+				return fmt.Errorf("%s: %w", entry.Name, err)
+			}
+		}
+
+		data := &cipher.StreamReader{
+			S: ctrStreamAt(entry.cipher, ctrIV(h.iv, entry.dataOffset), 0),
+			R: io.LimitReader(pr, int64(entry.Size)),
+		}
+		if err := fn(p, entry.Item, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package pkg_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/olebeck/go-pkg"
+)
+
+func TestStream(t *testing.T) {
+	items := []pkg.TestItem{
+		{Name: "sce_sys", Flags: 4},
+		{Name: "sce_sys/param.sfo", Data: pkg.BuildTestSfo(map[string]string{"TITLE": "stream"})},
+		{Name: "eboot.bin", Data: bytes.Repeat([]byte("0123456789"), 1000)},
+		{Name: "data/big.bin", Data: bytes.Repeat([]byte{1, 2, 3}, 100000)},
+	}
+	data := pkg.BuildTestPkg("EP0000-VITA00001_00-0000000000000000", 0x15, pkg.BuildTestSfo(map[string]string{"TITLE": "stream"}), items)
+
+	got := map[string][]byte{}
+	var streamed *pkg.Pkg
+	// MultiReader hides ReadAt from Stream
+	err := pkg.Stream(io.MultiReader(bytes.NewReader(data)), func(p *pkg.Pkg, item pkg.Item, r io.Reader) error {
+		streamed = p
+		if item.Name == "eboot.bin" {
+			// leave the rest unread, Stream has to skip it
+			b := make([]byte, 10)
+			_, err := io.ReadFull(r, b)
+			got[item.Name] = b
+			return err
+		}
+		b, err := io.ReadAll(r)
+		got[item.Name] = b
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got["data/big.bin"], items[3].Data) {
+		t.Error("data/big.bin content mismatch")
+	}
+	if !bytes.Equal(got["eboot.bin"], items[2].Data[:10]) {
+		t.Errorf("eboot.bin: %q", got["eboot.bin"])
+	}
+	if !bytes.Equal(got["sce_sys/param.sfo"], items[1].Data) {
+		t.Error("param.sfo content mismatch")
+	}
+
+	if streamed.ContentID != "EP0000-VITA00001_00-0000000000000000" || streamed.Sfo == nil || streamed.Sfo.String("TITLE") != "stream" {
+		t.Errorf("streamed package %s, sfo %v", streamed.ContentID, streamed.Sfo)
+	}
+	if all, err := streamed.Items(); err != nil || len(all) != len(items) || all[2].Name != "eboot.bin" {
+		t.Errorf("Items() = %v, %v", all, err)
+	}
+}
+
+func TestStreamSfoBehind(t *testing.T) {
+	data := pkg.BuildTestPkg("EP0000-VITA00001_00-0000000000000000", 0x15, pkg.BuildTestSfo(map[string]string{"TITLE": "stream"}), []pkg.TestItem{
+		{Name: "eboot.bin", Data: []byte("elf")},
+	})
+	// point param.sfo into the package header, the stream is past it once the metadata is read
+	binary.BigEndian.PutUint32(data[0x100+36:], 0x10)
+
+	err := pkg.Stream(bytes.NewReader(data), func(*pkg.Pkg, pkg.Item, io.Reader) error { return nil })
+	if err == nil {
+		t.Error("param.sfo behind the stream position was skipped")
+	}
+}
@@ -0,0 +1,66 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/olebeck/go-pkg/theme"
+)
+
+// Theme is the parsed theme.xml with the files it references
+type Theme struct {
+	*theme.Theme
+	// by path as written in theme.xml without surrounding whitespace, files missing from the package are left out
+	Files map[string]Asset
+}
+
+// Theme parses theme.xml of a theme package and looks up the files it references.
+// Theme packages are ContentType 0x1f and use key types 2 to 4 like every other vita package, no theme specific keys are needed.
+func (p *Pkg) Theme() (*Theme, error) {
+	if p.PkgType != PKG_TYPE_VITA_THEME {
+		return nil, fmt.Errorf("package type %d is not a theme", p.PkgType)
+	}
+	item, err := p.findItem("theme.xml")
+	if err != nil {
+		return nil, err
+	}
+	t, err := theme.Parse(item.sectionReader())
+	if err != nil {
+		return nil, fmt.Errorf("theme.xml: %w", err)
+	}
+
+	th := &Theme{Theme: t, Files: make(map[string]Asset)}
+	for _, name := range t.FileNames() {
+		clean, err := SafeName(name)
+		if err != nil {
+			continue
+		}
+		if item, err := p.findItem(clean); err == nil {
+			th.Files[name] = item.asset()
+		}
+	}
+	return th, nil
+}
+
+// file looks up name the way FileNames wrote it, trimmed
+func (t *Theme) file(name string) *Asset {
+	if a, ok := t.Files[strings.TrimSpace(name)]; ok {
+		return &a
+	}
+	return nil
+}
+
+// HomePreview returns the home screen preview image, nil if the package has none
+func (t *Theme) HomePreview() *Asset {
+	return t.file(t.Info.HomePreview)
+}
+
+// StartPreview returns the lock screen preview image, nil if the package has none
+func (t *Theme) StartPreview() *Asset {
+	return t.file(t.Info.StartPreview)
+}
+
+// PackageImage returns the thumbnail shown in the theme list, nil if the package has none
+func (t *Theme) PackageImage() *Asset {
+	return t.file(t.Info.PackageImage)
+}
@@ -0,0 +1,92 @@
+package theme
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// Theme is theme.xml at the root of a theme package, file paths are relative to it
+type Theme struct {
+	XMLName   xml.Name `xml:"theme"`
+	FormatVer string   `xml:"format-ver,attr"`
+	Package   string   `xml:"package,attr"`
+
+	Info        Info        `xml:"InfomationProperty"`
+	Home        Home        `xml:"HomeProperty"`
+	StartScreen StartScreen `xml:"StartScreenProperty"`
+}
+
+// Info holds the title and the preview images shown in the theme settings
+type Info struct {
+	ContentVer   string        `xml:"m_contentVer"`
+	Title        LocalizedText `xml:"m_title"`
+	Provider     LocalizedText `xml:"m_provider"`
+	HomePreview  string        `xml:"m_homePreviewFilePath"`
+	StartPreview string        `xml:"m_startPreviewFilePath"`
+	PackageImage string        `xml:"m_packageImageFilePath"`
+}
+
+type LocalizedText struct {
+	Default string           `xml:"m_default"`
+	Params  []LocalizedParam `xml:"m_param>LocalizedParam"`
+}
+
+type LocalizedParam struct {
+	Language string `xml:"m_language"`
+	Text     string `xml:"m_text"`
+}
+
+// Home is the home screen, one background per page
+type Home struct {
+	Backgrounds    []Background `xml:"m_bgParam>BackgroundParam"`
+	BGM            string       `xml:"m_bgmFilePath"`
+	IndicatorColor string       `xml:"m_indicatorColor"`
+}
+
+type Background struct {
+	Image     string `xml:"m_imageFilePath"`
+	Thumbnail string `xml:"m_thumbnailFilePath"`
+	FontColor string `xml:"m_fontColor"`
+}
+
+// StartScreen is the lock screen
+type StartScreen struct {
+	Image             string `xml:"m_filePath"`
+	DateColor         string `xml:"m_dateColor"`
+	DateLayout        string `xml:"m_dateLayout"`
+	NotifyBgColor     string `xml:"m_notifyBgColor"`
+	NotifyBorderColor string `xml:"m_notifyBorderColor"`
+	NotifyFontColor   string `xml:"m_notifyFontColor"`
+}
+
+func Parse(r io.Reader) (*Theme, error) {
+	var t Theme
+	if err := xml.NewDecoder(r).Decode(&t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// FileNames returns the files the theme references, in document order without duplicates
+func (t *Theme) FileNames() []string {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(files ...string) {
+		for _, name := range files {
+			name = strings.TrimSpace(name)
+			if name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	add(t.Info.HomePreview, t.Info.StartPreview, t.Info.PackageImage)
+	for _, bg := range t.Home.Backgrounds {
+		add(bg.Image, bg.Thumbnail)
+	}
+	add(t.Home.BGM, t.StartScreen.Image)
+	return names
+}
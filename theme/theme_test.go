@@ -0,0 +1,70 @@
+package theme_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/olebeck/go-pkg/theme"
+)
+
+const testTheme = `<?xml version="1.0" encoding="utf-8"?>
+<theme format-ver="01.00" package="0">
+  <InfomationProperty>
+    <m_contentVer>01.00</m_contentVer>
+    <m_title>
+      <m_default>Test Theme</m_default>
+      <m_param>
+        <LocalizedParam><m_language>ja</m_language><m_text>テスト</m_text></LocalizedParam>
+      </m_param>
+    </m_title>
+    <m_provider><m_default>Provider</m_default></m_provider>
+    <m_homePreviewFilePath>preview_page.png</m_homePreviewFilePath>
+    <m_startPreviewFilePath>preview_lockscreen.png</m_startPreviewFilePath>
+    <m_packageImageFilePath>preview_thumbnail.png</m_packageImageFilePath>
+  </InfomationProperty>
+  <HomeProperty>
+    <m_bgParam>
+      <BackgroundParam>
+        <m_imageFilePath>bg/page1.png</m_imageFilePath>
+        <m_thumbnailFilePath>bg/page1_thumb.png</m_thumbnailFilePath>
+        <m_fontColor>ffffffff</m_fontColor>
+      </BackgroundParam>
+      <BackgroundParam>
+        <m_imageFilePath>bg/page2.png</m_imageFilePath>
+      </BackgroundParam>
+    </m_bgParam>
+    <m_bgmFilePath>bgm.at9</m_bgmFilePath>
+  </HomeProperty>
+  <StartScreenProperty>
+    <m_filePath>lock.png</m_filePath>
+    <m_dateColor>ff00ff00</m_dateColor>
+  </StartScreenProperty>
+</theme>`
+
+func TestParse(t *testing.T) {
+	th, err := theme.Parse(strings.NewReader(testTheme))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if th.Info.Title.Default != "Test Theme" || len(th.Info.Title.Params) != 1 || th.Info.Title.Params[0].Language != "ja" {
+		t.Errorf("title = %+v", th.Info.Title)
+	}
+	if len(th.Home.Backgrounds) != 2 || th.Home.Backgrounds[0].FontColor != "ffffffff" {
+		t.Errorf("backgrounds = %+v", th.Home.Backgrounds)
+	}
+	if th.StartScreen.Image != "lock.png" || th.StartScreen.DateColor != "ff00ff00" {
+		t.Errorf("start screen = %+v", th.StartScreen)
+	}
+
+	want := []string{"preview_page.png", "preview_lockscreen.png", "preview_thumbnail.png", "bg/page1.png", "bg/page1_thumb.png", "bg/page2.png", "bgm.at9", "lock.png"}
+	if got := th.FileNames(); !slices.Equal(got, want) {
+		t.Errorf("FileNames() = %q, want %q", got, want)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	if _, err := theme.Parse(strings.NewReader("<livearea/>")); err == nil {
+		t.Error("expected error for wrong root element")
+	}
+}
@@ -0,0 +1,85 @@
+package pkg_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/olebeck/go-pkg"
+)
+
+func TestTheme(t *testing.T) {
+	themeXML := `<theme format-ver="01.00" package="0">
+<InfomationProperty>
+<m_title><m_default>Test Theme</m_default></m_title>
+<m_homePreviewFilePath>
+  preview_page.png
+</m_homePreviewFilePath>
+<m_startPreviewFilePath> preview_lockscreen.png</m_startPreviewFilePath>
+<m_packageImageFilePath>missing.png</m_packageImageFilePath>
+</InfomationProperty>
+</theme>`
+	data := pkg.BuildTestPkg("EP0000-PCSI00000_00-THEME00000000001", 0x1f, nil, []pkg.TestItem{
+		{Name: "theme.xml", Data: []byte(themeXML)},
+		{Name: "preview_page.png", Data: []byte("home")},
+		{Name: "preview_lockscreen.png", Data: []byte("lock")},
+	})
+	p, err := pkg.Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.PkgType != pkg.PKG_TYPE_VITA_THEME {
+		t.Fatalf("PkgType = %d", p.PkgType)
+	}
+
+	dir, err := p.InstallDir()
+	if err != nil || dir != "theme/PCSI00000-THEME00000000001" {
+		t.Errorf("InstallDir() = %q, %v", dir, err)
+	}
+
+	th, err := p.Theme()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if th.Info.Title.Default != "Test Theme" {
+		t.Errorf("title = %q", th.Info.Title.Default)
+	}
+	if a := th.HomePreview(); a == nil || readAsset(t, a) != "home" {
+		t.Error("home preview not found")
+	}
+	if a := th.StartPreview(); a == nil || readAsset(t, a) != "lock" {
+		t.Error("lock screen preview not found")
+	}
+	if th.PackageImage() != nil {
+		t.Error("missing package image resolved")
+	}
+
+	if _, err := testHomebrew(t).Theme(); err == nil {
+		t.Error("expected error for non theme package")
+	}
+}
+
+func TestThemeKeyTypes(t *testing.T) {
+	themeXML := `<theme><InfomationProperty><m_homePreviewFilePath>preview_page.png</m_homePreviewFilePath></InfomationProperty></theme>`
+	for _, keyType := range []byte{2, 3, 4} {
+		data := pkg.BuildTestPkgKeyType("EP0000-PCSI00000_00-THEME00000000001", 0x1f, keyType, nil, []pkg.TestItem{
+			{Name: "theme.xml", Data: []byte(themeXML)},
+			{Name: "preview_page.png", Data: []byte("home")},
+		})
+		p, err := pkg.Read(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("key type %d: %v", keyType, err)
+		}
+		th, err := p.Theme()
+		if err != nil {
+			t.Fatalf("key type %d: %v", keyType, err)
+		}
+		if a := th.HomePreview(); a == nil || readAsset(t, a) != "home" {
+			t.Errorf("key type %d: home preview not found", keyType)
+		}
+	}
+
+	ps3 := pkg.BuildTestPkg("EP0000-NPEB00000_00-THEME00000000001", 0x09, nil, nil)
+	if _, err := pkg.Read(bytes.NewReader(ps3)); err == nil {
+		t.Error("ps3 theme package accepted")
+	}
+}
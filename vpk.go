@@ -0,0 +1,69 @@
+package pkg
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// extensions of files that are already compressed and are stored as is
+var storedExts = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true,
+	".at9": true, ".at3": true, ".mp3": true, ".ogg": true, ".mp4": true, ".pmf": true,
+	".zip": true, ".gz": true, ".vpk": true, ".psarc": true,
+}
+
+func zipMethod(name string) uint16 {
+	if storedExts[strings.ToLower(path.Ext(name))] {
+		return zip.Store
+	}
+	return zip.Deflate
+}
+
+func writeZipFile(zw *zip.Writer, name string, r io.Reader) error {
+	w, err := zw.CreateHeader(&zip.FileHeader{
+		Name:   name,
+		Method: zipMethod(name),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// WriteVPK writes the app in fsys as a VitaShell vpk, use Pkg.FS to export a package
+func WriteVPK(w io.Writer, fsys fs.FS) error {
+	if _, err := fs.Stat(fsys, "sce_sys/param.sfo"); err != nil {
+		return errors.New("vpk: app has no sce_sys/param.sfo")
+	}
+
+	zw := zip.NewWriter(w)
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || name == "." {
+			return err
+		}
+		// vitashell recreates the package files when installing
+		if name == "sce_sys/package" && d.IsDir() {
+			return fs.SkipDir
+		}
+		if d.IsDir() {
+			_, err := zw.Create(name + "/")
+			return err
+		}
+
+		f, err := fsys.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return writeZipFile(zw, name, f)
+	})
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
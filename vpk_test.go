@@ -0,0 +1,78 @@
+package pkg_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/olebeck/go-pkg"
+)
+
+func testHomebrew(t *testing.T) *pkg.Pkg {
+	t.Helper()
+	data := pkg.BuildTestPkg("EP0000-VITA00001_00-0000000000000000", 0x15, nil, []pkg.TestItem{
+		{Name: "sce_sys", Flags: 4},
+		{Name: "sce_sys/param.sfo", Data: pkg.BuildTestSfo(map[string]string{"TITLE": "homebrew"})},
+		{Name: "sce_sys/icon0.png", Data: []byte("png data")},
+		{Name: "sce_sys/livearea/contents/template.xml", Data: []byte("<livearea/>")},
+		{Name: "eboot.bin", Data: bytes.Repeat([]byte("elf "), 100)},
+	})
+	p, err := pkg.Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func testHomebrewFS(t *testing.T) fs.FS {
+	t.Helper()
+	fsys, err := testHomebrew(t).FS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fsys
+}
+
+func TestFS(t *testing.T) {
+	if err := fstest.TestFS(testHomebrewFS(t), "eboot.bin", "sce_sys/param.sfo", "sce_sys/livearea/contents/template.xml"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWriteVPK(t *testing.T) {
+	var buf bytes.Buffer
+	if err := pkg.WriteVPK(&buf, testHomebrewFS(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	methods := map[string]uint16{}
+	for _, f := range zr.File {
+		methods[f.Name] = f.Method
+	}
+	if m, ok := methods["sce_sys/icon0.png"]; !ok || m != zip.Store {
+		t.Errorf("icon0.png: method %d, present %v", m, ok)
+	}
+	if m, ok := methods["eboot.bin"]; !ok || m != zip.Deflate {
+		t.Errorf("eboot.bin: method %d, present %v", m, ok)
+	}
+
+	f, err := zr.Open("eboot.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(f)
+	if !bytes.Equal(got, bytes.Repeat([]byte("elf "), 100)) {
+		t.Errorf("eboot.bin content mismatch")
+	}
+
+	if err := pkg.WriteVPK(io.Discard, fstest.MapFS{"eboot.bin": {}}); err == nil {
+		t.Error("vpk without param.sfo accepted")
+	}
+}
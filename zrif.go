@@ -0,0 +1,25 @@
+package pkg
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"io"
+)
+
+// DecodeZRIF decodes a zRIF string as listed by NoPayStation into the raw rif.
+// zRIFs are deflated against a preset dictionary (zrif_dict from pkg2zip),
+// which is not shipped here and has to be passed by the caller.
+// Install does this itself when given InstallOptions.ZRIF and ZRIFDict.
+func DecodeZRIF(zrif string, dict []byte) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(zrif)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zlib.NewReaderDict(bytes.NewReader(data), dict)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}